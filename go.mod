@@ -5,6 +5,7 @@ go 1.16
 require (
 	github.com/boltdb/bolt v1.3.2-0.20180302180052-fd01fc79c553 // indirect
 	github.com/buger/jsonparser v1.1.1
+	github.com/fxamacker/cbor/v2 v2.4.0
 	github.com/getblank/blank-router v0.2.6
 	github.com/getblank/blank-sr v0.1.40
 	github.com/getblank/rgx v0.0.0-20160518104151-4794b47be0bc
@@ -13,12 +14,15 @@ require (
 	github.com/globalsign/mgo v0.0.0-20181015135952-eeefdecb41b8 // indirect
 	github.com/go-chi/chi v3.3.3-0.20180202194135-e223a795a06a+incompatible
 	github.com/golang-jwt/jwt v3.2.2+incompatible
+	github.com/graphql-go/graphql v0.8.1
 	github.com/jinzhu/copier v0.0.0-20180308034124-7e38e58719c3 // indirect
 	github.com/json-iterator/go v0.0.0-20180408002546-f89479f5c0d8
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v0.0.0-20180320133207-05fbef0ca5da // indirect
 	github.com/pkg/errors v0.8.1-0.20180311214515-816c9085562c
 	github.com/robfig/cron v1.0.1-0.20171101201047-2315d5715e36
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	go.etcd.io/bbolt v1.3.6
 	go.uber.org/atomic v1.3.1 // indirect
 	go.uber.org/multierr v1.1.1-0.20180122172545-ddea229ff1df // indirect
 	go.uber.org/zap v1.7.2-0.20171031232209-f85c78b1dd99
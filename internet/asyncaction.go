@@ -0,0 +1,88 @@
+package internet
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/getblank/blank-router/taskq"
+)
+
+const (
+	headerPrefer            = "Prefer"
+	headerPreferenceApplied = "Preference-Applied"
+	preferRespondAsync      = "respond-async"
+	actionJobType           = "action"
+)
+
+// asyncJobTimeout is the max time of the async action if the action has no shorter timeout in the settings.
+var asyncJobTimeout = time.Hour
+
+// isAsyncAction returns true if the action is configured as async in the store settings
+// or the client asks for async response with "Prefer: respond-async" header.
+func isAsyncAction(r *http.Request, storeName, actionID string) (async, preferred bool) {
+	if prefersRespondAsync(r) {
+		return true, true
+	}
+
+	for _, id := range getStoreSettings(storeName).AsyncActions {
+		if id == actionID {
+			return true, false
+		}
+	}
+
+	return false, false
+}
+
+func prefersRespondAsync(r *http.Request) bool {
+	for _, h := range r.Header[headerPrefer] {
+		for _, p := range strings.Split(h, ",") {
+			if i := strings.IndexByte(p, ';'); i >= 0 {
+				p = p[:i]
+			}
+
+			if strings.EqualFold(strings.TrimSpace(p), preferRespondAsync) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// asyncActionResponse runs the action task in the background job and responds with 202 and job location.
+// Workers receive job id in the "jobId" argument and can report progress by publishing to com.jobs.<jobId>.
+// Jobs are kept in memory only, so they don't survive the restart and their location responds with 404 after it.
+func asyncActionResponse(w http.ResponseWriter, r *http.Request, cred credentials, t *taskq.Task, preferred bool) {
+	j := newJob(actionJobType, t.Store, cred)
+	t.Arguments["jobId"] = j.id
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), asyncJobTimeout)
+		defer cancel()
+
+		res, err := pushTask(ctx, t)
+		if err != nil {
+			removeTaskUploads(t)
+			log.Debugf("[async action] job %s for store %s failed, error: %v", j.id, t.Store, err)
+		}
+
+		j.finish(res, err)
+	}()
+
+	if preferred {
+		w.Header().Set(headerPreferenceApplied, preferRespondAsync)
+	}
+
+	w.Header().Set("Location", jobsURI+"/"+j.id)
+	apiResponse(w, r, http.StatusAccepted, j.info())
+}
+
+func init() {
+	if s := os.Getenv("BLANK_ASYNC_JOB_TIMEOUT"); len(s) > 0 {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			asyncJobTimeout = d
+		}
+	}
+}
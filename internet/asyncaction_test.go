@@ -0,0 +1,113 @@
+package internet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/getblank/blank-router/taskq"
+)
+
+func TestPrefersRespondAsync(t *testing.T) {
+	testData := []struct {
+		prefer string
+		res    bool
+	}{
+		{"", false},
+		{"respond-async", true},
+		{"return=minimal, Respond-Async; wait=10", true},
+		{"return=representation", false},
+	}
+
+	for _, v := range testData {
+		r := httptest.NewRequest("POST", "/", nil)
+		if len(v.prefer) > 0 {
+			r.Header.Set(headerPrefer, v.prefer)
+		}
+
+		if res := prefersRespondAsync(r); res != v.res {
+			t.Errorf("prefersRespondAsync(%q) = %v, expected %v", v.prefer, res, v.res)
+		}
+	}
+}
+
+func runAsyncAction(t *testing.T, fn func(task *taskq.Task) (interface{}, string)) *job {
+	served := serveTasks(1, fn)
+
+	r := httptest.NewRequest("POST", "/api/v1/orders/1/report", nil)
+	r.Header.Set(headerPrefer, preferRespondAsync)
+	r.Header.Set("Accept", mediaTypeMsgPack)
+	w := httptest.NewRecorder()
+	task := &taskq.Task{Type: taskq.DbAction, Store: "asyncTestStore", Arguments: map[string]interface{}{"actionId": "report"}}
+	asyncActionResponse(w, r, credentials{userID: "u1"}, task, true)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d", w.Code)
+	}
+
+	if w.Header().Get(headerPreferenceApplied) != preferRespondAsync {
+		t.Errorf("expected Preference-Applied header, got %q", w.Header().Get(headerPreferenceApplied))
+	}
+
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, jobsURI+"/") {
+		t.Fatalf("unexpected location %q", location)
+	}
+
+	if (<-served).Arguments["jobId"] == nil {
+		t.Error("jobId is not passed to the worker")
+	}
+
+	j, ok := getJob(strings.TrimPrefix(location, jobsURI+"/"))
+	if !ok {
+		t.Fatalf("job %s not found", location)
+	}
+
+	select {
+	case <-j.done:
+	case <-time.After(time.Second):
+		t.Fatal("job is not finished")
+	}
+
+	return j
+}
+
+func TestAsyncActionResponse(t *testing.T) {
+	j := runAsyncAction(t, func(*taskq.Task) (interface{}, string) { return "done", "" })
+	if info := j.info(); info.Status != jobStatusCompleted || info.Result != "done" || info.Progress != 1 {
+		t.Fatalf("unexpected job %+v", info)
+	}
+
+	rt := chi.NewRouter()
+	rt.With(withTestCred).Get(jobsURI+"/{id}", jobHandler)
+	r := httptest.NewRequest("GET", jobsURI+"/"+j.id, nil)
+	r.Header.Set("Accept", mediaTypeMsgPack)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected job status 200, got %d", w.Code)
+	}
+
+	j = runAsyncAction(t, func(*taskq.Task) (interface{}, string) { return nil, "report failed" })
+	if info := j.info(); info.Status != jobStatusFailed || info.Error != "report failed" {
+		t.Fatalf("unexpected job %+v", info)
+	}
+}
+
+func TestAsyncActionTimeout(t *testing.T) {
+	defer func(d time.Duration) { asyncJobTimeout = d }(asyncJobTimeout)
+	asyncJobTimeout = 10 * time.Millisecond
+
+	j := runAsyncAction(t, func(*taskq.Task) (interface{}, string) {
+		time.Sleep(50 * time.Millisecond)
+		return "late", ""
+	})
+
+	if info := j.info(); info.Status != jobStatusFailed {
+		t.Fatalf("expected failed job, got %+v", info)
+	}
+}
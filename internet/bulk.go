@@ -0,0 +1,284 @@
+package internet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"unicode"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/uuid"
+)
+
+const (
+	bulkOpCreate = "create"
+	bulkOpInsert = "insert"
+	bulkOpUpdate = "update"
+	bulkOpDelete = "delete"
+
+	applicationNDJSON = "application/x-ndjson"
+)
+
+var (
+	bulkConcurrency       = 8
+	bulkMaxBodySize int64 = 10 << 20
+
+	errBulkTransactionsNotSupported = errors.New("transactional bulk operations are not supported")
+	errBulkUnknownOp                = errors.New("unknown bulk operation")
+	errBulkNoID                     = errors.New("_id is required")
+	errBulkNoItem                   = errors.New("item is required")
+)
+
+type bulkOperation struct {
+	Op   string                 `json:"op"`
+	ID   interface{}            `json:"_id,omitempty"`
+	Item map[string]interface{} `json:"item,omitempty"`
+}
+
+type bulkResult struct {
+	Index  int          `json:"index"`
+	Op     string       `json:"op"`
+	ID     interface{}  `json:"_id,omitempty"`
+	Status int          `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Code   string       `json:"code,omitempty"`
+	Errors []fieldError `json:"errors,omitempty"`
+}
+
+func restBulkHandler(storeName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest bulk]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest bulk]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		if atomic, _ := strconv.ParseBool(r.URL.Query().Get("atomic")); atomic {
+			errorResponse(w, r, http.StatusNotImplemented, errBulkTransactionsNotSupported)
+			return
+		}
+
+		ops, err := decodeBulkOperations(w, r)
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var tokenInfo map[string]interface{}
+		if cred.claims != nil {
+			tokenInfo = cred.claims.toMap()
+		}
+
+		w.Header().Set(headerContentType, applicationNDJSON)
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+
+		results := make(chan bulkResult)
+		go func() {
+			processBulkOperations(r.Context(), storeName, cred.userID, tokenInfo, ops, results)
+			close(results)
+		}()
+
+		encoder := json.NewEncoder(w)
+		for res := range results {
+			if err := encoder.Encode(res); err != nil {
+				log.Debugf("[rest bulk] write error: %v", err)
+				continue
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// decodeBulkOperations reads operations from request body. Body can be a JSON array or NDJSON,
+// NDJSON is decoded line by line. Bodies larger than bulkMaxBodySize are rejected with 413.
+func decodeBulkOperations(w http.ResponseWriter, r *http.Request) ([]bulkOperation, error) {
+	body := &countingReader{r: http.MaxBytesReader(w, r.Body, bulkMaxBodySize)}
+	ops, err := readBulkOperations(bufio.NewReader(body))
+	if err != nil && body.n >= bulkMaxBodySize {
+		return nil, newAPIError(http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("request body is too large, max size is %d bytes", bulkMaxBodySize))
+	}
+
+	return ops, err
+}
+
+func readBulkOperations(br *bufio.Reader) ([]bulkOperation, error) {
+	var ops []bulkOperation
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return ops, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !unicode.IsSpace(rune(b[0])) {
+			if b[0] == '[' {
+				if err := json.NewDecoder(br).Decode(&ops); err != nil {
+					return nil, err
+				}
+
+				return ops, nil
+			}
+
+			break
+		}
+
+		br.ReadByte()
+	}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var op bulkOperation
+			if err := json.Unmarshal(line, &op); err != nil {
+				return nil, err
+			}
+
+			ops = append(ops, op)
+		}
+
+		if err == io.EOF {
+			return ops, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// processBulkOperations pushes tasks for all operations with bounded concurrency
+// and sends results to the provided channel as soon as they are ready.
+func processBulkOperations(ctx context.Context, storeName string, userID interface{}, tokenInfo map[string]interface{}, ops []bulkOperation, results chan<- bulkResult) {
+	sem := make(chan struct{}, bulkConcurrency)
+	var wg sync.WaitGroup
+	for i := range ops {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(index int, op bulkOperation) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			results <- runBulkOperation(ctx, storeName, userID, tokenInfo, index, op)
+		}(i, ops[i])
+	}
+
+	wg.Wait()
+}
+
+func runBulkOperation(ctx context.Context, storeName string, userID interface{}, tokenInfo map[string]interface{}, index int, op bulkOperation) bulkResult {
+	res := bulkResult{Index: index, Op: op.Op, ID: op.ID}
+	t := taskq.Task{
+		UserID: userID,
+		Store:  storeName,
+	}
+
+	switch op.Op {
+	case bulkOpCreate, bulkOpInsert:
+		if op.Item == nil {
+			return bulkError(res, http.StatusBadRequest, errBulkNoItem)
+		}
+
+		if err := validateStoreItem(storeName, op.Item, false); err != nil {
+			return bulkError(res, 0, err)
+		}
+
+		if op.Item["_id"] == nil {
+			op.Item["_id"] = uuid.NewV4()
+		}
+
+		res.ID = op.Item["_id"]
+		t.Type = taskq.DbSet
+		if op.Op == bulkOpInsert {
+			t.Type = taskq.DbInsert
+		}
+		t.Arguments = map[string]interface{}{"item": op.Item}
+	case bulkOpUpdate:
+		if op.ID == nil {
+			return bulkError(res, http.StatusBadRequest, errBulkNoID)
+		}
+
+		if op.Item == nil {
+			return bulkError(res, http.StatusBadRequest, errBulkNoItem)
+		}
+
+		if err := validateStoreItem(storeName, op.Item, true); err != nil {
+			return bulkError(res, 0, err)
+		}
+
+		op.Item["_id"] = op.ID
+		t.Type = taskq.DbSet
+		t.Arguments = map[string]interface{}{"item": op.Item}
+	case bulkOpDelete:
+		if op.ID == nil {
+			return bulkError(res, http.StatusBadRequest, errBulkNoID)
+		}
+
+		t.Type = taskq.DbDelete
+		t.Arguments = map[string]interface{}{"_id": op.ID}
+	default:
+		return bulkError(res, http.StatusBadRequest, errBulkUnknownOp)
+	}
+
+	if tokenInfo != nil {
+		t.Arguments["tokenInfo"] = tokenInfo
+	}
+
+	if _, err := pushTask(ctx, &t); err != nil {
+		return bulkError(res, 0, err)
+	}
+
+	res.Status = http.StatusOK
+	if op.Op == bulkOpCreate || op.Op == bulkOpInsert {
+		res.Status = http.StatusCreated
+	}
+
+	return res
+}
+
+func bulkError(res bulkResult, status int, err error) bulkResult {
+	e := toAPIError(status, err)
+	res.Status = e.Status
+	res.Error = e.Message
+	res.Code = e.Code
+	res.Errors = e.Fields
+
+	return res
+}
+
+func init() {
+	if c := os.Getenv("BLANK_BULK_CONCURRENCY"); len(c) > 0 {
+		if n, err := strconv.Atoi(c); err == nil && n > 0 {
+			bulkConcurrency = n
+		}
+	}
+
+	if s := os.Getenv("BLANK_BULK_MAX_BODY_SIZE"); len(s) > 0 {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+			bulkMaxBodySize = n
+		}
+	}
+}
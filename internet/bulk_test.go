@@ -0,0 +1,134 @@
+package internet
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/getblank/blank-router/taskq"
+)
+
+func TestDecodeBulkOperations(t *testing.T) {
+	testData := []struct {
+		body string
+		ops  []string
+	}{
+		{`[{"op":"delete","_id":"1"},{"op":"update","_id":"2"}]`, []string{"delete", "update"}},
+		{"\n  [{\"op\":\"delete\",\"_id\":\"1\"}]", []string{"delete"}},
+		{"{\"op\":\"delete\",\"_id\":\"1\"}\n\n{\"op\":\"update\",\"_id\":\"2\"}", []string{"delete", "update"}},
+		{"{\"op\":\"delete\",\"_id\":\"1\"}\r\n", []string{"delete"}},
+		{"", nil},
+	}
+
+	for _, v := range testData {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(v.body))
+		ops, err := decodeBulkOperations(httptest.NewRecorder(), r)
+		if err != nil {
+			t.Errorf("decodeBulkOperations(%q) error: %v", v.body, err)
+			continue
+		}
+
+		var res []string
+		for _, op := range ops {
+			res = append(res, op.Op)
+		}
+
+		if strings.Join(res, ",") != strings.Join(v.ops, ",") {
+			t.Errorf("decodeBulkOperations(%q) = %v, expected %v", v.body, res, v.ops)
+		}
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("{\"op\":\"delete\"}\nnot json"))
+	if _, err := decodeBulkOperations(httptest.NewRecorder(), r); err == nil {
+		t.Error("expected error for invalid NDJSON line")
+	}
+}
+
+func TestDecodeBulkOperationsLimit(t *testing.T) {
+	defer func(size int64) { bulkMaxBodySize = size }(bulkMaxBodySize)
+	bulkMaxBodySize = 32
+
+	body := strings.Repeat("{\"op\":\"delete\",\"_id\":\"1\"}\n", 3)
+	_, err := decodeBulkOperations(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	if e, ok := err.(*apiError); !ok || e.Status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 error, got %v", err)
+	}
+}
+
+func TestRestBulkHandler(t *testing.T) {
+	h := withTestCred(restBulkHandler("bulkTestStore"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/?atomic=true", strings.NewReader(`[]`)))
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 for atomic bulk, got %d", w.Code)
+	}
+
+	served := serveTasks(2, func(task *taskq.Task) (interface{}, string) {
+		if task.Arguments["_id"] == "missing" {
+			return nil, "not found"
+		}
+
+		return nil, ""
+	})
+
+	body := strings.Join([]string{
+		`{"op":"delete","_id":"1"}`,
+		`{"op":"delete","_id":"missing"}`,
+		`{"op":"delete"}`,
+		`{"op":"upsert","_id":"1"}`,
+	}, "\n")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	for range served {
+	}
+
+	var results []bulkResult
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	for scanner.Scan() {
+		var res bulkResult
+		if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		results = append(results, res)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+
+	expected := []int{http.StatusOK, http.StatusNotFound, http.StatusBadRequest, http.StatusBadRequest}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d: %s", len(expected), len(results), w.Body.String())
+	}
+
+	for i, res := range results {
+		if res.Status != expected[i] {
+			t.Errorf("operation %d: expected status %d, got %d (%s)", i, expected[i], res.Status, res.Error)
+		}
+	}
+}
+
+func TestRunBulkOperationErrors(t *testing.T) {
+	testData := []struct {
+		op   bulkOperation
+		code string
+	}{
+		{bulkOperation{Op: bulkOpUpdate, Item: map[string]interface{}{"name": "x"}}, errBulkNoID.Error()},
+		{bulkOperation{Op: bulkOpUpdate, ID: "1"}, errBulkNoItem.Error()},
+		{bulkOperation{Op: bulkOpCreate}, errBulkNoItem.Error()},
+	}
+
+	for _, v := range testData {
+		res := runBulkOperation(context.Background(), "bulkTestStore", "u1", nil, 0, v.op)
+		if res.Status != http.StatusBadRequest || res.Error != v.code {
+			t.Errorf("%s: unexpected result %d %q", v.op.Op, res.Status, res.Error)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package internet
+
+import (
+	"container/list"
+	"expvar"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getblank/blank-router/taskq"
+)
+
+const (
+	cacheKeyByUser   = "user"
+	cacheKeyByRoles  = "roles"
+	cacheKeyByShared = "shared"
+
+	defaultCacheMaxEntries = 1000
+	defaultCacheTTL        = time.Minute
+)
+
+var (
+	caches       = map[string]*storeCache{}
+	cachesLocker sync.RWMutex
+
+	// cache metrics are available on the intranet /debug/vars endpoint
+	cacheMetrics = expvar.NewMap("blankCache")
+)
+
+// cacheSettings enables read-through cache of DbGet, DbFind and widget data results for the store, e.g.:
+//
+//	"cache": {"maxEntries": 500, "ttl": "30s", "keyBy": "roles"}
+//
+// KeyBy sets who shares cached results: "user" (default), "roles" from the token or "shared" for everyone.
+// Use "roles" and "shared" only if store access doesn't depend on the user itself.
+type cacheSettings struct {
+	MaxEntries int    `json:"maxEntries,omitempty"`
+	TTL        string `json:"ttl,omitempty"`
+	KeyBy      string `json:"keyBy,omitempty"`
+}
+
+type storeCache struct {
+	maxEntries int
+	ttl        time.Duration
+	keyBy      string
+	entries    map[string]*list.Element
+	lru        *list.List
+	generation uint64
+	hits       *expvar.Int
+	misses     *expvar.Int
+	evictions  *expvar.Int
+	size       *expvar.Int
+	sync.Mutex
+}
+
+type cacheEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func newStoreCache(storeName string, s cacheSettings) *storeCache {
+	c := &storeCache{
+		maxEntries: s.MaxEntries,
+		ttl:        defaultCacheTTL,
+		keyBy:      s.KeyBy,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultCacheMaxEntries
+	}
+
+	if len(s.TTL) > 0 {
+		if d, err := time.ParseDuration(s.TTL); err == nil && d > 0 {
+			c.ttl = d
+		} else {
+			log.Warnf("Invalid cache ttl %q in store %s settings", s.TTL, storeName)
+		}
+	}
+
+	switch c.keyBy {
+	case cacheKeyByUser, cacheKeyByRoles, cacheKeyByShared:
+	default:
+		c.keyBy = cacheKeyByUser
+	}
+
+	c.hits, c.misses, c.evictions, c.size = cacheMetric(storeName, "hits"), cacheMetric(storeName, "misses"), cacheMetric(storeName, "evictions"), cacheMetric(storeName, "entries")
+	c.size.Set(0)
+
+	return c
+}
+
+// cacheMetric returns metric of the store cache. Metrics are kept when caches are recreated on config update.
+func cacheMetric(storeName, name string) *expvar.Int {
+	key := storeName + "." + name
+	if v, ok := cacheMetrics.Get(key).(*expvar.Int); ok {
+		return v
+	}
+
+	v := new(expvar.Int)
+	cacheMetrics.Set(key, v)
+	return v
+}
+
+// get returns copy of the cached value, so callers can modify it.
+func (c *storeCache) get(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expiresAt) {
+		c.remove(el)
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	c.hits.Add(1)
+	return copyValue(e.value), true
+}
+
+// set stores the value if cache was not invalidated after the generation was received.
+func (c *storeCache) set(key string, value interface{}, generation uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key, copyValue(value), time.Now().Add(c.ttl)})
+	c.size.Add(1)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+func (c *storeCache) currentGeneration() uint64 {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.generation
+}
+
+func (c *storeCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+	c.size.Add(-1)
+}
+
+func (c *storeCache) flush() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.generation++
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+	c.size.Set(0)
+}
+
+// taskCacheKey returns cache of the task store and the key for the task result.
+// Nil cache is returned for tasks which are not cached.
+func taskCacheKey(t *taskq.Task) (*storeCache, string) {
+	switch t.Type {
+	case taskq.DbGet, taskq.DbFind, taskq.WidgetData:
+	default:
+		return nil, ""
+	}
+
+	c := getStoreCache(t.Store)
+	if c == nil {
+		return nil, ""
+	}
+
+	args := make(map[string]interface{}, len(t.Arguments))
+	for k, v := range t.Arguments {
+		if k != "tokenInfo" {
+			args[k] = v
+		}
+	}
+
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return nil, ""
+	}
+
+	var principal string
+	switch c.keyBy {
+	case cacheKeyByShared:
+	case cacheKeyByRoles:
+		tokenInfo, _ := t.Arguments["tokenInfo"].(map[string]interface{})
+		principal = "roles:" + rolesKey(tokenInfo["roles"])
+	default:
+		principal = fmt.Sprintf("user:%v", t.UserID)
+	}
+
+	return c, principal + "|" + t.Type + "|" + string(encoded)
+}
+
+func rolesKey(v interface{}) string {
+	values, _ := v.([]interface{})
+	roles := make([]string, 0, len(values))
+	for _, r := range values {
+		roles = append(roles, fmt.Sprint(r))
+	}
+	sort.Strings(roles)
+
+	return strings.Join(roles, ",")
+}
+
+func getStoreCache(storeName string) *storeCache {
+	cachesLocker.RLock()
+	defer cachesLocker.RUnlock()
+
+	return caches[storeName]
+}
+
+// invalidateStoreCache removes all cached results of the store.
+func invalidateStoreCache(storeName string) {
+	if c := getStoreCache(storeName); c != nil {
+		c.flush()
+	}
+}
+
+// onCacheEvent invalidates store cache by store change events sent by workers.
+func onCacheEvent(uri string) {
+	if !strings.HasPrefix(uri, uriSubStores+".") {
+		return
+	}
+
+	invalidateStoreCache(strings.TrimPrefix(uri, uriSubStores+"."))
+}
+
+// updateCaches recreates caches for the stores from the settings.
+func updateCaches(s map[string]storeSettings) {
+	res := map[string]*storeCache{}
+	for storeName, ss := range s {
+		if ss.Cache != nil {
+			res[storeName] = newStoreCache(storeName, *ss.Cache)
+		}
+	}
+
+	cachesLocker.Lock()
+	caches = res
+	cachesLocker.Unlock()
+}
+
+// copyValue returns deep copy of the decoded task result.
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for k, el := range val {
+			res[k] = copyValue(el)
+		}
+
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(val))
+		for i, el := range val {
+			res[i] = copyValue(el)
+		}
+
+		return res
+	}
+
+	return v
+}
+
+func isWriteTask(typ string) bool {
+	switch typ {
+	case taskq.DbSet, taskq.DbInsert, taskq.DbDelete, taskq.DbPush, taskq.DbAction, taskDbTransition:
+		return true
+	}
+
+	return false
+}
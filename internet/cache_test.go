@@ -0,0 +1,53 @@
+package internet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreCache(t *testing.T) {
+	c := newStoreCache("cacheTestStore", cacheSettings{MaxEntries: 2, TTL: "1h"})
+	c.set("a", map[string]interface{}{"items": []interface{}{"1", "2"}}, c.currentGeneration())
+	c.set("b", "b", c.currentGeneration())
+
+	res, ok := c.get("a")
+	if !ok || !reflect.DeepEqual(res, map[string]interface{}{"items": []interface{}{"1", "2"}}) {
+		t.Fatalf("unexpected cached value %v", res)
+	}
+
+	// cached value must not be changed by the caller
+	res.(map[string]interface{})["items"].([]interface{})[0] = "changed"
+	if res, _ := c.get("a"); res.(map[string]interface{})["items"].([]interface{})[0] != "1" {
+		t.Fatal("cached value was modified")
+	}
+
+	// "b" is the least recently used entry
+	c.set("c", "c", c.currentGeneration())
+	if _, ok := c.get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+
+	if c.evictions.Value() < 1 || c.hits.Value() < 2 || c.misses.Value() < 1 {
+		t.Errorf("unexpected metrics: hits %d, misses %d, evictions %d", c.hits.Value(), c.misses.Value(), c.evictions.Value())
+	}
+
+	generation := c.currentGeneration()
+	c.flush()
+	if _, ok := c.get("a"); ok {
+		t.Error("entry was not removed by flush")
+	}
+
+	// result of the task started before invalidation is not stored
+	c.set("a", "stale", generation)
+	if _, ok := c.get("a"); ok {
+		t.Error("stale entry was stored")
+	}
+
+	c.ttl = time.Nanosecond
+	c.set("d", "d", c.currentGeneration())
+	time.Sleep(time.Millisecond)
+	if _, ok := c.get("d"); ok {
+		t.Error("expired entry was returned")
+	}
+}
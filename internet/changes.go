@@ -0,0 +1,324 @@
+package internet
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getblank/blank-sr/config"
+
+	"github.com/getblank/blank-one/sessions"
+)
+
+const (
+	textEventStream = "text/event-stream"
+
+	headerLastEventID = "Last-Event-ID"
+
+	sseConnIDPrefix     = "sse-"
+	sseStreamBufferSize = 256
+	sseHeartbeatPeriod  = 15 * time.Second
+
+	// sseResumeWindow is the time subscription of the disconnected client is kept alive
+	// to collect events for resuming with Last-Event-ID.
+	sseResumeWindow = time.Minute
+)
+
+var (
+	sseBufferSize = 1000
+	// event ids start from the current time to detect resuming after restart
+	sseHub = &changesHub{subscriptions: map[string]*sseSubscription{}, lastID: uint64(time.Now().UnixNano() / int64(time.Millisecond))}
+)
+
+// changesHub delivers intranet events to SSE streams and keeps bounded buffer of recent events for resuming.
+type changesHub struct {
+	subscriptions map[string]*sseSubscription
+	buffer        []sseEvent
+	lastID        uint64
+	sync.RWMutex
+}
+
+// sseSubscription is registered in session store with connID, the same way as WAMP subscription.
+// Workers address events to connID, so all streams of the same session and store share it.
+type sseSubscription struct {
+	connID      string
+	sessionID   string
+	uri         string
+	streams     map[*sseStream]struct{}
+	removeTimer *time.Timer
+}
+
+type sseStream struct {
+	events chan sseEvent
+	closed chan struct{}
+	once   sync.Once
+}
+
+type sseEvent struct {
+	id          uint64
+	uri         string
+	data        []byte
+	subscribers map[string]struct{}
+}
+
+func (s *sseStream) close() {
+	s.once.Do(func() { close(s.closed) })
+}
+
+func (e sseEvent) addressedTo(connID string) bool {
+	if e.subscribers == nil {
+		return true
+	}
+
+	_, ok := e.subscribers[connID]
+	return ok
+}
+
+// publish saves event to the buffer and sends it to the streams of addressed subscriptions.
+// Nil subscribers means event for all subscriptions of the uri.
+func (h *changesHub) publish(uri string, event interface{}, subscribers []string) {
+	if !strings.HasPrefix(uri, uriSubStores+".") {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("[changes] can't marshal event for uri %s, error: %v", uri, err)
+		return
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	e := sseEvent{uri: uri, data: data}
+	if subscribers != nil {
+		e.subscribers = make(map[string]struct{}, len(subscribers))
+		for _, connID := range subscribers {
+			if strings.HasPrefix(connID, sseConnIDPrefix) {
+				e.subscribers[connID] = struct{}{}
+			}
+		}
+
+		if len(e.subscribers) == 0 {
+			return
+		}
+	}
+
+	h.lastID++
+	e.id = h.lastID
+	h.buffer = append(h.buffer, e)
+	if len(h.buffer) > sseBufferSize {
+		h.buffer = h.buffer[len(h.buffer)-sseBufferSize:]
+	}
+
+	for connID, sub := range h.subscriptions {
+		if sub.uri != uri || !e.addressedTo(connID) {
+			continue
+		}
+
+		for s := range sub.streams {
+			select {
+			case s.events <- e:
+			default:
+				// slow client is disconnected to resume from the buffer later
+				log.Warnf("[changes] stream buffer of %s is full, closing stream", connID)
+				s.close()
+			}
+		}
+	}
+}
+
+// subscribe registers the stream and returns buffered events after lastEventID.
+// If some events after lastEventID were evicted from the buffer, complete is false.
+func (h *changesHub) subscribe(cred credentials, uri string, extra interface{}, s *sseStream, lastEventID uint64, resume bool) (connID string, missed []sseEvent, complete bool, err error) {
+	connID = sseConnIDPrefix + cred.sessionID + "-" + uri
+	h.Lock()
+	defer h.Unlock()
+
+	sub, ok := h.subscriptions[connID]
+	if !ok {
+		if err := sessions.AddSubscription(cred.sessionID, connID, uri, extra); err != nil {
+			return "", nil, false, err
+		}
+
+		sub = &sseSubscription{connID: connID, sessionID: cred.sessionID, uri: uri, streams: map[*sseStream]struct{}{}}
+		h.subscriptions[connID] = sub
+	}
+
+	if sub.removeTimer != nil {
+		sub.removeTimer.Stop()
+		sub.removeTimer = nil
+	}
+
+	sub.streams[s] = struct{}{}
+	if !resume {
+		return connID, nil, true, nil
+	}
+
+	switch {
+	case lastEventID > h.lastID:
+		complete = false
+	case len(h.buffer) == 0:
+		complete = lastEventID == h.lastID
+	default:
+		complete = h.buffer[0].id <= lastEventID+1
+	}
+
+	for _, e := range h.buffer {
+		if e.id > lastEventID && e.uri == uri && e.addressedTo(connID) {
+			missed = append(missed, e)
+		}
+	}
+
+	return connID, missed, complete, nil
+}
+
+// unsubscribe removes the stream. Subscription without streams is removed after sseResumeWindow.
+func (h *changesHub) unsubscribe(connID string, s *sseStream) {
+	h.Lock()
+	defer h.Unlock()
+
+	sub, ok := h.subscriptions[connID]
+	if !ok {
+		return
+	}
+
+	delete(sub.streams, s)
+	if len(sub.streams) > 0 || sub.removeTimer != nil {
+		return
+	}
+
+	sub.removeTimer = time.AfterFunc(sseResumeWindow, func() {
+		h.Lock()
+		defer h.Unlock()
+
+		if len(sub.streams) > 0 || h.subscriptions[connID] != sub {
+			return
+		}
+
+		delete(h.subscriptions, connID)
+		if err := sessions.DeleteConnection(sub.sessionID, connID); err != nil {
+			log.Debugf("[changes] can't delete connection %s, error: %v", connID, err)
+		}
+	})
+}
+
+func restChangesHandler(store config.Store) http.HandlerFunc {
+	uri := uriSubStores + "." + store.Store
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest changes]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest changes]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+			return
+		}
+
+		// subscription params are the same as the WAMP com.stores subscription data
+		var extra interface{}
+		if q := r.URL.Query().Get("query"); len(q) > 0 {
+			if err := json.Unmarshal([]byte(q), &extra); err != nil {
+				errorResponse(w, r, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		var lastEventID uint64
+		lastID := r.Header.Get(headerLastEventID)
+		if len(lastID) == 0 {
+			lastID = r.URL.Query().Get("lastEventId")
+		}
+
+		resume := len(lastID) > 0
+		if resume {
+			var err error
+			if lastEventID, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+				errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("invalid %s", headerLastEventID))
+				return
+			}
+		}
+
+		s := &sseStream{events: make(chan sseEvent, sseStreamBufferSize), closed: make(chan struct{})}
+		connID, missed, complete, err := sseHub.subscribe(cred, uri, extra, s, lastEventID, resume)
+		if err != nil {
+			errorResponse(w, r, http.StatusForbidden, err)
+			return
+		}
+		defer sseHub.unsubscribe(connID, s)
+
+		w.Header().Set(headerContentType, textEventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		// reset event tells the client that some events were lost and it must reload data
+		if resume && !complete {
+			if _, err := fmt.Fprint(w, "event: reset\ndata: {}\n\n"); err != nil {
+				return
+			}
+		}
+
+		for _, e := range missed {
+			if err := writeSSEEvent(w, e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(sseHeartbeatPeriod)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-s.closed:
+				return
+			case e := <-s.events:
+				if err := writeSSEEvent(w, e); err != nil {
+					log.Debugf("[rest changes] write error: %v", err)
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := sessions.CheckSession(cred.sessionID); err != nil {
+					return
+				}
+
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					log.Debugf("[rest changes] write error: %v", err)
+					return
+				}
+			}
+
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSEEvent(w http.ResponseWriter, e sseEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.id, e.data)
+	return err
+}
+
+func init() {
+	if s := os.Getenv("BLANK_SSE_BUFFER_SIZE"); len(s) > 0 {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			sseBufferSize = n
+		}
+	}
+}
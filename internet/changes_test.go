@@ -0,0 +1,53 @@
+package internet
+
+import (
+	"testing"
+)
+
+func TestChangesHub(t *testing.T) {
+	h := &changesHub{subscriptions: map[string]*sseSubscription{}, lastID: 100}
+	uri := uriSubStores + ".users"
+	s := &sseStream{events: make(chan sseEvent, 10), closed: make(chan struct{})}
+	connID := sseConnIDPrefix + "session-" + uri
+	h.subscriptions[connID] = &sseSubscription{connID: connID, uri: uri, streams: map[*sseStream]struct{}{s: {}}}
+
+	h.publish(uri, []interface{}{"create", 1}, nil)
+	h.publish(uri, "other", []string{sseConnIDPrefix + "another"})
+	h.publish(uri, "wamp only", []string{"wamp-conn"})
+	h.publish(uri, "mine", []string{"wamp-conn", connID})
+	h.publish(uriSubConfig, "config", nil)
+
+	if len(s.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(s.events))
+	}
+
+	if e := <-s.events; e.id != 101 || string(e.data) != `["create",1]` {
+		t.Fatalf("unexpected event %d: %s", e.id, e.data)
+	}
+
+	if e := <-s.events; e.id != 103 || string(e.data) != `"mine"` {
+		t.Fatalf("unexpected event %d: %s", e.id, e.data)
+	}
+
+	if len(h.buffer) != 3 {
+		t.Fatalf("expected 3 buffered events, got %d", len(h.buffer))
+	}
+
+	resumed := &sseStream{events: make(chan sseEvent, 10), closed: make(chan struct{})}
+	_, missed, complete, err := h.subscribe(credentials{sessionID: "session"}, uri, nil, resumed, 101, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !complete || len(missed) != 1 || missed[0].id != 103 {
+		t.Fatalf("unexpected resume result: complete %v, missed %v", complete, missed)
+	}
+
+	if _, _, complete, _ = h.subscribe(credentials{sessionID: "session"}, uri, nil, resumed, 50, true); complete {
+		t.Fatal("resume from evicted event must be incomplete")
+	}
+
+	if _, _, complete, _ = h.subscribe(credentials{sessionID: "session"}, uri, nil, resumed, 500, true); complete {
+		t.Fatal("resume from unknown event must be incomplete")
+	}
+}
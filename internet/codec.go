@@ -0,0 +1,391 @@
+package internet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+const (
+	mediaTypeJSON    = "application/json"
+	mediaTypeMsgPack = "application/msgpack"
+	mediaTypeCBOR    = "application/cbor"
+	mediaTypeXML     = "application/xml"
+)
+
+// codec encodes and decodes /api/v1 request and response bodies.
+type codec interface {
+	// ContentType returns value of the Content-Type header of encoded responses.
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// apiMaxBodySize is the max size of the request body decoded by the codecs
+	apiMaxBodySize int64 = 10 << 20
+
+	codecs       = map[string]codec{}
+	codecsLocker sync.RWMutex
+
+	mapStringInterfaceType = reflect.TypeOf(map[string]interface{}(nil))
+)
+
+// registerCodec registers codec for the media types.
+func registerCodec(c codec, mediaTypes ...string) {
+	codecsLocker.Lock()
+	defer codecsLocker.Unlock()
+
+	for _, mt := range mediaTypes {
+		codecs[mt] = c
+	}
+}
+
+func getCodec(mediaType string) (codec, bool) {
+	codecsLocker.RLock()
+	defer codecsLocker.RUnlock()
+
+	c, ok := codecs[mediaType]
+	return c, ok
+}
+
+// responseCodec selects codec by the Accept header of the request. JSON is used if nothing acceptable is registered.
+func responseCodec(r *http.Request) codec {
+	accept := r.Header.Get("Accept")
+	if len(accept) == 0 {
+		return jsonCodec{}
+	}
+
+	type acceptRange struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if qv, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qv, 64); err != nil {
+				continue
+			}
+		}
+
+		if q > 0 {
+			ranges = append(ranges, acceptRange{mediaType, q})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	codecsLocker.RLock()
+	defer codecsLocker.RUnlock()
+
+	for _, ar := range ranges {
+		if c, ok := codecs[ar.mediaType]; ok {
+			return c
+		}
+
+		if ar.mediaType == "*/*" || ar.mediaType == "application/*" {
+			break
+		}
+	}
+
+	return jsonCodec{}
+}
+
+// decodeRequestBody decodes request body by its Content-Type. Body without Content-Type is decoded as JSON.
+// Bodies larger than apiMaxBodySize are rejected with 413.
+func decodeRequestBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	c := codec(jsonCodec{})
+	if ct := r.Header.Get(headerContentType); len(ct) > 0 {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return newAPIError(http.StatusUnsupportedMediaType, "unsupported_media_type", err.Error())
+		}
+
+		var ok bool
+		if c, ok = getCodec(mediaType); !ok {
+			return newAPIError(http.StatusUnsupportedMediaType, "unsupported_media_type", fmt.Sprintf("unsupported content type %q", mediaType))
+		}
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, apiMaxBodySize))
+	if err != nil {
+		if int64(len(body)) == apiMaxBodySize {
+			return newAPIError(http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("request body is too large, max size is %d bytes", apiMaxBodySize))
+		}
+
+		return newAPIError(http.StatusBadRequest, "invalid_body", err.Error())
+	}
+
+	if err := c.Unmarshal(body, v); err != nil {
+		return newAPIError(http.StatusBadRequest, "invalid_body", err.Error())
+	}
+
+	return nil
+}
+
+// apiResponse writes data encoded with the codec negotiated by the Accept header of the request.
+// Other codecs get values typed by the props of the store of the request.
+func apiResponse(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	c := responseCodec(r)
+	if _, ok := c.(jsonCodec); ok {
+		jsonResponseWithStatus(w, status, data)
+		return
+	}
+
+	encoded, err := c.Marshal(typedValue(data, responseProps(r)))
+	if err != nil {
+		errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set(headerContentType, c.ContentType())
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	if _, err := w.Write(encoded); err != nil {
+		log.Debugf("[apiResponse] write error: %v", err)
+	}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                        { return applicationJSON }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return mediaTypeMsgPack }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := msgpack.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	return normalizeDecoded(v)
+}
+
+type cborCodec struct {
+	enc cbor.EncMode
+	dec cbor.DecMode
+}
+
+func newCBORCodec() cborCodec {
+	enc, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano, TimeTag: cbor.EncTagRequired}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+
+	dec, err := cbor.DecOptions{DefaultMapType: mapStringInterfaceType}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return cborCodec{enc, dec}
+}
+
+func (cborCodec) ContentType() string { return mediaTypeCBOR }
+
+func (c cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return c.enc.Marshal(v)
+}
+
+func (c cborCodec) Unmarshal(data []byte, v interface{}) error {
+	if err := c.dec.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	return normalizeDecoded(v)
+}
+
+// responseProps returns props of the store set to the request context by storeMiddleware.
+func responseProps(r *http.Request) map[string]config.Prop {
+	storeName, _ := r.Context().Value(storeKey).(string)
+	if len(storeName) == 0 {
+		return nil
+	}
+
+	store, _ := getStoreConfig(storeName)
+	return store.Props
+}
+
+// typedValue prepares values decoded from the worker JSON for the typed codecs by the types of the store props:
+// int props become ints and date props become dates, other values are left as is.
+// Maps which are not props, e.g. results of actions, keep the store props for their nested items.
+func typedValue(v interface{}, props map[string]config.Prop) interface{} {
+	if val, ok := v.(listPage); ok {
+		// page is passed as the generic map with the same keys as in JSON, so all codecs get typed items
+		page := map[string]interface{}{"items": typedValue(val.Items, props), "take": val.Take}
+		if val.Count != nil {
+			page["count"] = int64(*val.Count)
+		}
+
+		if len(val.NextCursor) > 0 {
+			page["nextCursor"] = val.NextCursor
+		}
+
+		if len(val.PrevCursor) > 0 {
+			page["prevCursor"] = val.PrevCursor
+		}
+
+		return page
+	}
+
+	if len(props) == 0 {
+		return v
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for k, el := range val {
+			if p, ok := props[k]; ok {
+				res[k] = typedPropValue(el, p)
+			} else {
+				res[k] = typedValue(el, props)
+			}
+		}
+
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(val))
+		for i, el := range val {
+			res[i] = typedValue(el, props)
+		}
+
+		return res
+	}
+
+	return v
+}
+
+func typedPropValue(v interface{}, p config.Prop) interface{} {
+	switch p.Type {
+	case config.PropInt:
+		if f, ok := v.(float64); ok && f == math.Trunc(f) && math.Abs(f) <= 1<<53 {
+			return int64(f)
+		}
+	case config.PropDate:
+		if s, ok := v.(string); ok {
+			if t, ok := parseDateString(s); ok {
+				return t
+			}
+		}
+	case config.PropObject, config.PropObjectList:
+		if len(p.Props) > 0 {
+			return typedValue(v, p.Props)
+		}
+	}
+
+	return v
+}
+
+// parseDateString parses full RFC 3339 timestamps only, so plain strings are not converted by accident.
+func parseDateString(s string) (time.Time, bool) {
+	if len(s) < len("2006-01-02T15:04:05Z") || s[4] != '-' || s[10] != 'T' {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
+
+// normalizeDecoded converts unsigned and small numbers decoded by binary codecs to int64 and float64,
+// so decoded values have the same types regardless of the codec.
+func normalizeDecoded(v interface{}) error {
+	switch p := v.(type) {
+	case *interface{}:
+		*p = normalizeValue(*p)
+	case *map[string]interface{}:
+		for k, el := range *p {
+			(*p)[k] = normalizeValue(el)
+		}
+	case *[]interface{}:
+		for i, el := range *p {
+			(*p)[i] = normalizeValue(el)
+		}
+	}
+
+	return nil
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, el := range val {
+			val[k] = normalizeValue(el)
+		}
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for k, el := range val {
+			res[fmt.Sprint(k)] = normalizeValue(el)
+		}
+
+		return res
+	case []interface{}:
+		for i, el := range val {
+			val[i] = normalizeValue(el)
+		}
+	case int8:
+		return int64(val)
+	case int16:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case int:
+		return int64(val)
+	case uint8:
+		return int64(val)
+	case uint16:
+		return int64(val)
+	case uint32:
+		return int64(val)
+	case uint64:
+		if val <= math.MaxInt64 {
+			return int64(val)
+		}
+
+		return float64(val)
+	case float32:
+		return float64(val)
+	}
+
+	return v
+}
+
+func init() {
+	registerCodec(jsonCodec{}, mediaTypeJSON)
+	registerCodec(msgpackCodec{}, mediaTypeMsgPack, "application/x-msgpack")
+	registerCodec(newCBORCodec(), mediaTypeCBOR)
+	registerCodec(xmlCodec{}, mediaTypeXML, "text/xml")
+
+	if s := os.Getenv("BLANK_API_MAX_BODY_SIZE"); len(s) > 0 {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+			apiMaxBodySize = n
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package internet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+var codecTestProps = map[string]config.Prop{
+	"_id":    {Type: config.PropString},
+	"count":  {Type: config.PropInt},
+	"price":  {Type: config.PropFloat},
+	"ratio":  {Type: config.PropFloat},
+	"date":   {Type: config.PropDate},
+	"code":   {Type: config.PropString},
+	"nested": {Type: config.PropObject, Props: map[string]config.Prop{"n": {Type: config.PropInt}}},
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	date := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	value := map[string]interface{}{
+		"_id":    "1",
+		"count":  float64(42),
+		"price":  9.5,
+		"ratio":  float64(2),
+		"active": true,
+		"date":   "2020-05-17T10:30:00Z",
+		"code":   "2020-05-17T10:30:00Z",
+		"tags":   []interface{}{"a", "b"},
+		"nested": map[string]interface{}{"empty": nil, "1st": "x", "n": float64(1)},
+	}
+	expected := map[string]interface{}{
+		"_id":    "1",
+		"count":  int64(42),
+		"price":  9.5,
+		"ratio":  float64(2),
+		"active": true,
+		"date":   date,
+		"code":   "2020-05-17T10:30:00Z",
+		"tags":   []interface{}{"a", "b"},
+		"nested": map[string]interface{}{"empty": nil, "1st": "x", "n": int64(1)},
+	}
+
+	for _, mediaType := range []string{mediaTypeMsgPack, mediaTypeCBOR, mediaTypeXML} {
+		c, ok := getCodec(mediaType)
+		if !ok {
+			t.Fatalf("codec for %s is not registered", mediaType)
+		}
+
+		encoded, err := c.Marshal(typedValue(value, codecTestProps))
+		if err != nil {
+			t.Fatalf("%s marshal error: %v", mediaType, err)
+		}
+
+		var res map[string]interface{}
+		if err := c.Unmarshal(encoded, &res); err != nil {
+			t.Fatalf("%s unmarshal error: %v", mediaType, err)
+		}
+
+		if d, ok := res["date"].(time.Time); ok {
+			res["date"] = d.UTC()
+		}
+
+		if !reflect.DeepEqual(res, expected) {
+			t.Errorf("%s roundtrip result %#v, expected %#v", mediaType, res, expected)
+		}
+	}
+}
+
+func TestTypedValue(t *testing.T) {
+	count := 3
+	page := listPage{Items: []interface{}{map[string]interface{}{"count": float64(1), "other": float64(2)}}, Take: 10, Count: &count}
+	expected := map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"count": int64(1), "other": float64(2)}},
+		"take":  10,
+		"count": int64(3),
+	}
+
+	if res := typedValue(page, codecTestProps); !reflect.DeepEqual(res, expected) {
+		t.Errorf("typed page %#v, expected %#v", res, expected)
+	}
+
+	// values are not typed without the store props
+	if res := typedValue(map[string]interface{}{"count": float64(1)}, nil); !reflect.DeepEqual(res, map[string]interface{}{"count": float64(1)}) {
+		t.Errorf("unexpected typed value %#v", res)
+	}
+}
+
+func TestDecodeRequestBodyLimit(t *testing.T) {
+	defer func(size int64) { apiMaxBodySize = size }(apiMaxBodySize)
+	apiMaxBodySize = 16
+
+	var v interface{}
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"John"}`))
+	if err := decodeRequestBody(httptest.NewRecorder(), r, &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"John Smith"}`))
+	err := decodeRequestBody(httptest.NewRecorder(), r, &v)
+	if e, ok := err.(*apiError); !ok || e.Status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 error, got %v", err)
+	}
+}
+
+func TestResponseCodec(t *testing.T) {
+	testData := []struct {
+		accept string
+		res    string
+	}{
+		{"", applicationJSON},
+		{"application/msgpack", mediaTypeMsgPack},
+		{"text/html, application/cbor;q=0.9, */*;q=0.8", mediaTypeCBOR},
+		{"application/xml;q=0.5, application/json", applicationJSON},
+		{"text/xml", applicationXML},
+		{"text/plain", applicationJSON},
+	}
+
+	for _, v := range testData {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", v.accept)
+		if res := responseCodec(r).ContentType(); res != v.res {
+			t.Errorf("responseCodec(%q) = %s, expected %s", v.accept, res, v.res)
+		}
+	}
+}
@@ -0,0 +1,619 @@
+package internet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+	exportFormatXLSX   = "xlsx"
+
+	exportPageSize = 500
+
+	textCSV          = "text/csv; charset=utf-8"
+	applicationXLSX  = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	defaultI18nLang  = "en"
+	xlsxSheetNameMax = 31
+
+	// exportFormulaPrefixes are the first chars of text cells which spreadsheets evaluate as formulas
+	exportFormulaPrefixes = "=+-@"
+)
+
+var (
+	i18nRGX = regexp.MustCompile(`\{\{\s*\$i18n\.([^\s{}]+)\s*\}\}`)
+
+	exportSkippedPropTypes = map[string]struct{}{
+		config.PropVirtualClient: {},
+		config.PropPassword:      {},
+		config.PropAction:        {},
+		config.PropWidget:        {},
+		config.PropComments:      {},
+	}
+)
+
+type exportColumn struct {
+	prop  string
+	label string
+}
+
+// rowWriter writes exported rows in the specific format.
+type rowWriter interface {
+	WriteHeader(columns []exportColumn) error
+	WriteRow(columns []exportColumn, item map[string]interface{}) error
+	Close() error
+}
+
+func restExportHandler(store config.Store) http.HandlerFunc {
+	storeName := store.Store
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest export]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest export]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		params := r.URL.Query()
+		query, orderBy, err := listQueryFromParams(store, params)
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if len(orderBy) == 0 {
+			orderBy = defaultOrderBy(store)
+		}
+
+		orderBy = stableOrderBy(orderBy)
+
+		columns, err := exportColumns(store, params.Get("fields"), requestLang(r))
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		format := params.Get("format")
+		if len(format) == 0 {
+			format = exportFormatCSV
+		}
+
+		var contentType string
+		var newWriter func(io.Writer) (rowWriter, error)
+		switch format {
+		case exportFormatCSV:
+			contentType, newWriter = textCSV, newCSVRowWriter
+		case exportFormatNDJSON:
+			contentType, newWriter = applicationNDJSON, newNDJSONRowWriter
+		case exportFormatXLSX:
+			contentType = applicationXLSX
+			newWriter = func(w io.Writer) (rowWriter, error) { return newXLSXRowWriter(w, storeName) }
+		default:
+			errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("unknown export format %q, supported formats: csv, ndjson, xlsx", format))
+			return
+		}
+
+		// ordering props are loaded too as the cursor of the next page is made from them
+		var props []string
+		seen := map[string]struct{}{}
+		add := func(prop string) {
+			if _, ok := seen[prop]; !ok {
+				seen[prop] = struct{}{}
+				props = append(props, prop)
+			}
+		}
+
+		add("_id")
+		for _, col := range columns {
+			add(col.prop)
+		}
+
+		for _, f := range parseOrderBy(orderBy) {
+			add(f.prop)
+		}
+
+		pageSize := exportPageSize
+		if s := getStoreSettings(storeName); s.MaxPageSize < pageSize {
+			pageSize = s.MaxPageSize
+		}
+
+		var tokenInfo map[string]interface{}
+		if cred.claims != nil {
+			tokenInfo = cred.claims.toMap()
+		}
+
+		fetchPage := func(cursor *pageCursor) ([]interface{}, error) {
+			q := query
+			if cursor != nil {
+				q = mergeQueries(query, cursorQuery(*cursor, orderBy))
+			}
+
+			t := taskq.Task{
+				Type:   taskq.DbFind,
+				UserID: cred.userID,
+				Store:  storeName,
+				Arguments: map[string]interface{}{
+					"query": map[string]interface{}{"query": q, "skip": 0, "take": pageSize, "orderBy": orderBy, "props": props},
+				},
+			}
+			if tokenInfo != nil {
+				t.Arguments["tokenInfo"] = tokenInfo
+			}
+
+			res, err := pushTask(r.Context(), &t)
+			if err != nil {
+				return nil, err
+			}
+
+			result, _ := res.(map[string]interface{})
+			items, _ := result["items"].([]interface{})
+			return items, nil
+		}
+
+		// first page is requested before writing headers to respond with the proper status on errors
+		items, err := fetchPage(nil)
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		w.Header().Set(headerContentType, contentType)
+		w.Header().Set(headerContentDisposition, fmt.Sprintf("attachment; filename=%q", storeName+"."+format))
+		w.Header().Set("Trailer", headerStreamError)
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+
+		rw, err := newWriter(w)
+		if err != nil {
+			log.Errorf("[rest export] can't create %s writer, error: %v", format, err)
+			return
+		}
+
+		defer func() {
+			if err := rw.Close(); err != nil {
+				log.Debugf("[rest export] close writer error: %v", err)
+			}
+		}()
+
+		if err := rw.WriteHeader(columns); err != nil {
+			log.Debugf("[rest export] write error: %v", err)
+			return
+		}
+
+		for {
+			for _, item := range items {
+				m, _ := item.(map[string]interface{})
+				if err := rw.WriteRow(columns, m); err != nil {
+					log.Debugf("[rest export] write error: %v", err)
+					return
+				}
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+
+			if len(items) < pageSize {
+				return
+			}
+
+			select {
+			case <-r.Context().Done():
+				log.Debugf("[rest export] export of store %s cancelled by client", storeName)
+				return
+			default:
+			}
+
+			cursor, ok := newPageCursor(items[len(items)-1], orderBy, false)
+			if !ok {
+				return
+			}
+
+			if items, err = fetchPage(&cursor); err != nil {
+				log.Errorf("[rest export] export of store %s interrupted, error: %v", storeName, err)
+				abortStream(w, false, err)
+				return
+			}
+		}
+	}
+}
+
+// exportColumns returns columns for export. If fields are not provided, store table columns are used,
+// or all exportable props if store has no table columns.
+func exportColumns(store config.Store, fields, lang string) ([]exportColumn, error) {
+	var props []string
+	if len(fields) > 0 {
+		for _, f := range strings.Split(fields, ",") {
+			f = strings.TrimSpace(f)
+			if len(f) == 0 {
+				continue
+			}
+
+			if _, err := findProp(store, f); err != nil {
+				return nil, err
+			}
+
+			props = append(props, f)
+		}
+	}
+
+	if len(props) == 0 {
+		props = tableColumnProps(store)
+	}
+
+	if len(props) == 0 {
+		props = exportableProps(store)
+	}
+
+	columns := make([]exportColumn, len(props))
+	for i, prop := range props {
+		label := prop
+		if p, err := findProp(store, prop); err == nil && len(p.Label) > 0 {
+			label = translate(store, p.Label, lang)
+		}
+
+		columns[i] = exportColumn{prop: prop, label: label}
+	}
+
+	return columns, nil
+}
+
+func tableColumnProps(store config.Store) []string {
+	var res []string
+	for _, col := range store.TableColumns {
+		var prop string
+		switch v := col.(type) {
+		case string:
+			prop = v
+		case map[string]interface{}:
+			prop, _ = v["prop"].(string)
+		}
+
+		if _, err := findProp(store, prop); len(prop) > 0 && err == nil {
+			res = append(res, prop)
+		}
+	}
+
+	return res
+}
+
+func exportableProps(store config.Store) []string {
+	var res []string
+	for name, p := range store.Props {
+		if _, skip := exportSkippedPropTypes[p.Type]; skip || strings.HasPrefix(name, "__") {
+			continue
+		}
+
+		res = append(res, name)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		a, b := store.Props[res[i]], store.Props[res[j]]
+		if (res[i] == "_id") != (res[j] == "_id") {
+			return res[i] == "_id"
+		}
+
+		if a.FormOrder != b.FormOrder {
+			return a.FormOrder < b.FormOrder
+		}
+
+		return res[i] < res[j]
+	})
+
+	return res
+}
+
+// translate replaces {{$i18n.key}} templates in the text with store translations.
+func translate(store config.Store, text, lang string) string {
+	dict, ok := store.I18n[lang].(map[string]interface{})
+	if !ok {
+		dict, _ = store.I18n[defaultI18nLang].(map[string]interface{})
+	}
+
+	return i18nRGX.ReplaceAllStringFunc(text, func(s string) string {
+		key := i18nRGX.FindStringSubmatch(s)[1]
+		if v, ok := lookupProp(dict, key).(string); ok {
+			return v
+		}
+
+		return s
+	})
+}
+
+// requestLang returns language from lang query param or Accept-Language header.
+func requestLang(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); len(lang) > 0 {
+		return lang
+	}
+
+	if al := r.Header.Get("Accept-Language"); len(al) >= 2 {
+		return strings.ToLower(al[:2])
+	}
+
+	return defaultI18nLang
+}
+
+// exportCellValue returns the text of the cell.
+func exportCellValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(encoded)
+}
+
+// escapeFormula prefixes with the quote strings which spreadsheets take as formulas when opening CSV.
+func escapeFormula(s string) string {
+	if len(s) > 0 && strings.ContainsRune(exportFormulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func newCSVRowWriter(w io.Writer) (rowWriter, error) {
+	return &csvRowWriter{csv.NewWriter(w)}, nil
+}
+
+func (c *csvRowWriter) WriteHeader(columns []exportColumn) error {
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		record[i] = col.label
+	}
+
+	return c.w.Write(record)
+}
+
+func (c *csvRowWriter) WriteRow(columns []exportColumn, item map[string]interface{}) error {
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		v := lookupProp(item, col.prop)
+		if s, ok := v.(string); ok {
+			record[i] = escapeFormula(s)
+			continue
+		}
+
+		record[i] = exportCellValue(v)
+	}
+
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvRowWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonRowWriter struct {
+	w io.Writer
+}
+
+func newNDJSONRowWriter(w io.Writer) (rowWriter, error) {
+	return &ndjsonRowWriter{w}, nil
+}
+
+func (n *ndjsonRowWriter) WriteHeader(columns []exportColumn) error {
+	return nil
+}
+
+// WriteRow writes the row as JSON object with props in the order of columns.
+func (n *ndjsonRowWriter) WriteRow(columns []exportColumn, item map[string]interface{}) error {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, col := range columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		key, err := json.Marshal(col.prop)
+		if err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(lookupProp(item, col.prop))
+		if err != nil {
+			return err
+		}
+
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteString("}\n")
+
+	_, err := n.w.Write(b.Bytes())
+	return err
+}
+
+func (n *ndjsonRowWriter) Close() error {
+	return nil
+}
+
+// xlsxRowWriter writes the minimal SpreadsheetML package with the single sheet and inline strings,
+// so rows are streamed without keeping them in memory.
+type xlsxRowWriter struct {
+	zw    *zip.Writer
+	sheet io.Writer
+	row   int
+}
+
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{
+		"[Content_Types].xml",
+		xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`,
+	},
+	{
+		"_rels/.rels",
+		xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		"xl/_rels/workbook.xml.rels",
+		xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`,
+	},
+}
+
+func newXLSXRowWriter(w io.Writer, sheetName string) (rowWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxStaticParts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(sheetName) > xlsxSheetNameMax {
+		sheetName = sheetName[:xlsxSheetNameMax]
+	}
+
+	f, err := zw.Create("xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(f, xml.Header+`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" `+
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`); err != nil {
+		return nil, err
+	}
+
+	if err := xml.EscapeText(f, []byte(sheetName)); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(f, `" sheetId="1" r:id="rId1"/></sheets></workbook>`); err != nil {
+		return nil, err
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(sheet, xml.Header+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+
+	return &xlsxRowWriter{zw: zw, sheet: sheet}, nil
+}
+
+func (x *xlsxRowWriter) WriteHeader(columns []exportColumn) error {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i] = col.label
+	}
+
+	return x.writeRow(values)
+}
+
+func (x *xlsxRowWriter) WriteRow(columns []exportColumn, item map[string]interface{}) error {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i] = lookupProp(item, col.prop)
+	}
+
+	return x.writeRow(values)
+}
+
+func (x *xlsxRowWriter) writeRow(values []interface{}) error {
+	x.row++
+	if _, err := fmt.Fprintf(x.sheet, `<row r="%d">`, x.row); err != nil {
+		return err
+	}
+
+	for _, v := range values {
+		var err error
+		switch val := v.(type) {
+		case nil:
+			_, err = io.WriteString(x.sheet, `<c/>`)
+		case float64:
+			_, err = fmt.Fprintf(x.sheet, `<c t="n"><v>%s</v></c>`, strconv.FormatFloat(val, 'f', -1, 64))
+		case bool:
+			b := 0
+			if val {
+				b = 1
+			}
+			_, err = fmt.Fprintf(x.sheet, `<c t="b"><v>%d</v></c>`, b)
+		default:
+			if _, err = io.WriteString(x.sheet, `<c t="inlineStr"><is><t xml:space="preserve">`); err != nil {
+				return err
+			}
+
+			if err = xml.EscapeText(x.sheet, []byte(exportCellValue(v))); err != nil {
+				return err
+			}
+
+			_, err = io.WriteString(x.sheet, `</t></is></c>`)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(x.sheet, `</row>`)
+	return err
+}
+
+func (x *xlsxRowWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+
+	return x.zw.Close()
+}
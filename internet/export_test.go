@@ -0,0 +1,192 @@
+package internet
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+var exportTestColumns = []exportColumn{{"name", "Name"}, {"total", "Total"}, {"paid", "Paid"}}
+
+func writeExportRows(t *testing.T, rw rowWriter, items ...map[string]interface{}) {
+	if err := rw.WriteHeader(exportTestColumns); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range items {
+		if err := rw.WriteRow(exportTestColumns, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportCellValue(t *testing.T) {
+	testData := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, ""},
+		{"John", "John"},
+		{"-5 discount", "-5 discount"},
+		{float64(-1.5), "-1.5"},
+		{true, "true"},
+	}
+
+	for _, v := range testData {
+		if res := exportCellValue(v.value); res != v.expected {
+			t.Errorf("exportCellValue(%v): expected %q, got %q", v.value, v.expected, res)
+		}
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	testData := []struct {
+		value    string
+		expected string
+	}{
+		{"", ""},
+		{"John", "John"},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+	}
+
+	for _, v := range testData {
+		if res := escapeFormula(v.value); res != v.expected {
+			t.Errorf("escapeFormula(%q): expected %q, got %q", v.value, v.expected, res)
+		}
+	}
+}
+
+func TestCSVRowWriter(t *testing.T) {
+	var buf bytes.Buffer
+	rw, _ := newCSVRowWriter(&buf)
+	writeExportRows(t, rw,
+		map[string]interface{}{"name": "John, Jr.", "total": float64(-10), "paid": true},
+		map[string]interface{}{"name": "=1+2"},
+	)
+
+	expected := "Name,Total,Paid\n\"John, Jr.\",-10,true\n'=1+2,,\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestNDJSONRowWriter(t *testing.T) {
+	var buf bytes.Buffer
+	rw, _ := newNDJSONRowWriter(&buf)
+	writeExportRows(t, rw,
+		map[string]interface{}{"total": float64(10), "name": "=1+2", "paid": true},
+		map[string]interface{}{"name": "Jane"},
+	)
+
+	// props are written in the order of columns, values are not escaped as they are not evaluated
+	expected := `{"name":"=1+2","total":10,"paid":true}` + "\n" + `{"name":"Jane","total":null,"paid":null}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestXLSXRowWriter(t *testing.T) {
+	var buf bytes.Buffer
+	rw, err := newXLSXRowWriter(&buf, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeExportRows(t, rw, map[string]interface{}{"name": "-5 discount", "total": float64(-3), "paid": false})
+
+	ir, err := newXLSXImportReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row, err := ir.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// inline strings are not evaluated as formulas, so they are written as is
+	if row["Name"] != "-5 discount" {
+		t.Errorf("expected raw string, got %v", row["Name"])
+	}
+
+	if row["Total"] != float64(-3) {
+		t.Errorf("expected number -3, got %v", row["Total"])
+	}
+
+	if row["Paid"] != false {
+		t.Errorf("expected false, got %v", row["Paid"])
+	}
+}
+
+func TestRestExportPagesByNotExportedProp(t *testing.T) {
+	settingsLocker.Lock()
+	settings["exportTestOrders"] = storeSettings{DefaultPageSize: 2, MaxPageSize: 2}
+	settingsLocker.Unlock()
+	defer func() {
+		settingsLocker.Lock()
+		delete(settings, "exportTestOrders")
+		settingsLocker.Unlock()
+	}()
+
+	store := config.Store{
+		Store: "exportTestOrders",
+		Props: map[string]config.Prop{
+			"name":      {Type: config.PropString},
+			"createdAt": {Type: config.PropDate},
+		},
+	}
+
+	rt := chi.NewRouter()
+	rt.With(withTestCred).Get("/orders/_export", restExportHandler(store))
+
+	// orders created at the same time are kept by the worker in the order which differs from the _id order
+	items := []map[string]interface{}{
+		{"_id": "5", "name": "e", "createdAt": "2020-01-02"},
+		{"_id": "4", "name": "d", "createdAt": "2020-01-02"},
+		{"_id": "3", "name": "c", "createdAt": "2020-01-02"},
+		{"_id": "2", "name": "b", "createdAt": "2020-01-01"},
+		{"_id": "1", "name": "a", "createdAt": "2020-01-01"},
+	}
+
+	served := serveTasks(3, func(task *taskq.Task) (interface{}, string) {
+		return fakeFind(items, task), ""
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/orders/_export?format=ndjson&fields=name&orderBy=-createdAt", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		names = append(names, strings.TrimSuffix(strings.TrimPrefix(line, `{"name":"`), `"}`))
+	}
+
+	expected := []string{"e", "d", "c", "b", "a"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("exported names %v, expected: %v", names, expected)
+	}
+
+	for task := range served {
+		findQuery, _ := task.Arguments["query"].(map[string]interface{})
+		if findQuery["orderBy"] != "-createdAt,-_id" {
+			t.Errorf("unexpected orderBy %v", findQuery["orderBy"])
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package internet
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+const (
+	filterOpEq       = "eq"
+	filterOpNe       = "ne"
+	filterOpGt       = "gt"
+	filterOpLt       = "lt"
+	filterOpIn       = "in"
+	filterOpContains = "contains"
+
+	filterValuePlaceholder = "$value"
+)
+
+var filterParamRGX = regexp.MustCompile(`^filter\[([^\[\]]+)\](?:\[([a-zA-Z]+)\])?$`)
+
+// filterError is returned when list query params can't be applied to the store.
+type filterError struct {
+	msg string
+}
+
+func (e *filterError) Error() string {
+	return e.msg
+}
+
+func newFilterError(format string, args ...interface{}) error {
+	return &filterError{fmt.Sprintf(format, args...)}
+}
+
+// listQueryFromParams makes DbFind query and orderBy from the list query params.
+// Returned orderBy is empty if it was not provided.
+func listQueryFromParams(store config.Store, params url.Values) (map[string]interface{}, string, error) {
+	var query map[string]interface{}
+	if q := params.Get("query"); len(q) > 0 {
+		if err := json.Unmarshal([]byte(q), &query); err != nil {
+			return nil, "", err
+		}
+	}
+
+	filterQuery, err := parseListFilters(store, params)
+	if err != nil {
+		return nil, "", err
+	}
+
+	orderBy := params.Get("orderBy")
+	if sort := params.Get("sort"); len(sort) > 0 {
+		if orderBy, err = parseSortParam(store, sort); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return mergeQueries(query, filterQuery), orderBy, nil
+}
+
+func defaultOrderBy(store config.Store) string {
+	if len(store.OrderBy) > 0 {
+		return store.OrderBy
+	}
+
+	return "_id"
+}
+
+// parseListFilters makes DbFind query from filter[prop][op]=value and filter=name params.
+func parseListFilters(store config.Store, params url.Values) (map[string]interface{}, error) {
+	conditions := map[string]map[string]interface{}{}
+	for key, values := range params {
+		match := filterParamRGX.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		propName, op := match[1], match[2]
+		if len(op) == 0 {
+			op = filterOpEq
+		}
+
+		prop, err := findProp(store, propName)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, raw := range values {
+			cond, err := filterCondition(prop, propName, op, raw)
+			if err != nil {
+				return nil, err
+			}
+
+			if conditions[propName] == nil {
+				conditions[propName] = map[string]interface{}{}
+			}
+
+			for k, v := range cond {
+				conditions[propName][k] = v
+			}
+		}
+	}
+
+	query := map[string]interface{}{}
+	for propName, cond := range conditions {
+		if v, ok := cond["$eq"]; ok && len(cond) == 1 {
+			query[propName] = v
+			continue
+		}
+
+		query[propName] = cond
+	}
+
+	var named []map[string]interface{}
+	for _, f := range params["filter"] {
+		for _, f := range strings.Split(f, ",") {
+			if len(f) == 0 {
+				continue
+			}
+
+			q, err := namedFilterQuery(store, f)
+			if err != nil {
+				return nil, err
+			}
+
+			named = append(named, q)
+		}
+	}
+
+	return mergeQueries(append([]map[string]interface{}{query}, named...)...), nil
+}
+
+// findProp returns description of the store prop. Nested props are separated by dots.
+func findProp(store config.Store, propName string) (config.Prop, error) {
+	props := store.Props
+	path := strings.Split(propName, ".")
+	for i, name := range path {
+		p, ok := props[name]
+		if !ok && i == 0 && name == "_id" {
+			p, ok = config.Prop{Type: config.PropString}, true
+		}
+
+		if !ok {
+			return config.Prop{}, newFilterError("unknown field %q: store %q has no such prop", propName, store.Store)
+		}
+
+		if i == len(path)-1 {
+			return p, nil
+		}
+
+		if p.Type != config.PropObject && p.Type != config.PropObjectList {
+			return config.Prop{}, newFilterError("unknown field %q: prop %q of store %q has no nested props", propName, name, store.Store)
+		}
+
+		props = p.Props
+	}
+
+	return config.Prop{}, newFilterError("unknown field %q", propName)
+}
+
+func filterCondition(p config.Prop, propName, op, raw string) (map[string]interface{}, error) {
+	switch op {
+	case filterOpEq, filterOpNe, filterOpGt, filterOpLt:
+		if (op == filterOpGt || op == filterOpLt) && !isOrderedProp(p) {
+			return nil, newFilterError("operator %q is not supported for field %q of type %q", op, propName, p.Type)
+		}
+
+		v, err := coerceFilterValue(p, propName, raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"$" + op: v}, nil
+	case filterOpIn:
+		var values []interface{}
+		for _, s := range strings.Split(raw, ",") {
+			v, err := coerceFilterValue(p, propName, s)
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, v)
+		}
+
+		return map[string]interface{}{"$in": values}, nil
+	case filterOpContains:
+		switch p.Type {
+		case config.PropString, config.PropUUID:
+			return map[string]interface{}{"$regex": regexp.QuoteMeta(raw), "$options": "i"}, nil
+		case config.PropRefList, config.PropFileList:
+			v, err := coerceFilterValue(p, propName, raw)
+			if err != nil {
+				return nil, err
+			}
+
+			return map[string]interface{}{"$eq": v}, nil
+		}
+
+		return nil, newFilterError("operator %q is not supported for field %q of type %q", op, propName, p.Type)
+	}
+
+	return nil, newFilterError("unknown operator %q for field %q, supported operators: eq, ne, gt, lt, in, contains", op, propName)
+}
+
+func isOrderedProp(p config.Prop) bool {
+	switch p.Type {
+	case config.PropInt, config.PropFloat, config.PropDate, config.PropDateOnly, config.PropString, config.PropUUID:
+		return true
+	}
+
+	return false
+}
+
+// coerceFilterValue converts raw query param value to the type of the prop.
+func coerceFilterValue(p config.Prop, propName, raw string) (interface{}, error) {
+	switch p.Type {
+	case config.PropInt:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, newFilterError("invalid value %q for field %q: int expected", raw, propName)
+		}
+
+		return v, nil
+	case config.PropFloat:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, newFilterError("invalid value %q for field %q: float expected", raw, propName)
+		}
+
+		return v, nil
+	case config.PropBool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, newFilterError("invalid value %q for field %q: bool expected", raw, propName)
+		}
+
+		return v, nil
+	case config.PropDate, config.PropDateOnly:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+			if v, err := time.Parse(layout, raw); err == nil {
+				return v.UTC(), nil
+			}
+		}
+
+		return nil, newFilterError("invalid value %q for field %q: RFC 3339 date expected", raw, propName)
+	case config.PropRef, config.PropRefList:
+		if refStore, ok := getStoreConfig(p.Store); ok && refStore.Props["_id"].Type == config.PropInt {
+			v, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, newFilterError("invalid value %q for field %q: int _id of store %q expected", raw, propName, p.Store)
+			}
+
+			return v, nil
+		}
+
+		return raw, nil
+	case config.PropString, config.PropUUID, config.PropFile, config.PropFileList:
+		return raw, nil
+	}
+
+	return nil, newFilterError("filtering by field %q of type %q is not supported", propName, p.Type)
+}
+
+// namedFilterQuery returns query of the store filter. Filter value can be provided after colon: filter=name:value.
+// "$value" placeholders in the filter query will be replaced with the value.
+func namedFilterQuery(store config.Store, nameAndValue string) (map[string]interface{}, error) {
+	name, raw := nameAndValue, ""
+	var hasValue bool
+	if i := strings.Index(nameAndValue, ":"); i >= 0 {
+		name, raw, hasValue = nameAndValue[:i], nameAndValue[i+1:], true
+	}
+
+	f, ok := store.Filters[name]
+	if !ok {
+		return nil, newFilterError("unknown filter %q: store %q has no such filter", name, store.Store)
+	}
+
+	propName := f.FilterBy
+	if len(propName) == 0 {
+		propName = name
+	}
+
+	var value interface{} = raw
+	p, propErr := findProp(store, propName)
+	if propErr == nil && hasValue {
+		var err error
+		if value, err = coerceFilterValue(p, propName, raw); err != nil {
+			return nil, err
+		}
+	}
+
+	if f.Query == nil {
+		if !hasValue {
+			return nil, newFilterError("filter %q requires value: use filter=%s:value", name, name)
+		}
+
+		if propErr != nil {
+			return nil, propErr
+		}
+
+		return map[string]interface{}{propName: value}, nil
+	}
+
+	q, ok := replaceFilterValue(f.Query, value).(map[string]interface{})
+	if !ok {
+		return nil, newFilterError("filter %q has invalid query", name)
+	}
+
+	return q, nil
+}
+
+func replaceFilterValue(in, value interface{}) interface{} {
+	switch v := in.(type) {
+	case string:
+		if v == filterValuePlaceholder {
+			return value
+		}
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			res[k] = replaceFilterValue(item, value)
+		}
+
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = replaceFilterValue(item, value)
+		}
+
+		return res
+	}
+
+	return in
+}
+
+// parseSortParam validates comma separated sort fields against store props.
+func parseSortParam(store config.Store, sort string) (string, error) {
+	fields := parseOrderBy(sort)
+	res := make([]string, len(fields))
+	for i, f := range fields {
+		if _, err := findProp(store, f.prop); err != nil {
+			return "", err
+		}
+
+		res[i] = f.prop
+		if f.desc {
+			res[i] = "-" + f.prop
+		}
+	}
+
+	return strings.Join(res, ","), nil
+}
+
+// parseFieldsParam returns props for projection from comma separated fields param.
+// Props required for pagination are always included.
+func parseFieldsParam(store config.Store, fields, orderBy string) ([]string, error) {
+	var res []string
+	seen := map[string]struct{}{}
+	add := func(prop string) {
+		if _, ok := seen[prop]; !ok {
+			seen[prop] = struct{}{}
+			res = append(res, prop)
+		}
+	}
+
+	for _, f := range strings.Split(fields, ",") {
+		f = strings.TrimSpace(f)
+		if len(f) == 0 {
+			continue
+		}
+
+		if _, err := findProp(store, f); err != nil {
+			return nil, err
+		}
+
+		add(f)
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	add("_id")
+	for _, f := range parseOrderBy(orderBy) {
+		add(f.prop)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,137 @@
+package internet
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+var filtersTestStore = config.Store{
+	Store: "users",
+	Props: map[string]config.Prop{
+		"_id":     {Type: config.PropString},
+		"name":    {Type: config.PropString},
+		"age":     {Type: config.PropInt},
+		"active":  {Type: config.PropBool},
+		"created": {Type: config.PropDate},
+		"address": {Type: config.PropObject, Props: map[string]config.Prop{
+			"city": {Type: config.PropString},
+		}},
+	},
+	Filters: map[string]config.Filter{
+		"adults": {Query: map[string]interface{}{"age": map[string]interface{}{"$gt": 17}}},
+		"age":    {},
+	},
+}
+
+func TestParseListFilters(t *testing.T) {
+	testData := []struct {
+		in    string
+		res   map[string]interface{}
+		isErr bool
+	}{
+		{
+			"filter[name]=John",
+			map[string]interface{}{"name": "John"},
+			false,
+		},
+		{
+			"filter[age][gt]=18&filter[age][lt]=30",
+			map[string]interface{}{"age": map[string]interface{}{"$gt": 18, "$lt": 30}},
+			false,
+		},
+		{
+			"filter[active][ne]=true",
+			map[string]interface{}{"active": map[string]interface{}{"$ne": true}},
+			false,
+		},
+		{
+			"filter[age][in]=1,2",
+			map[string]interface{}{"age": map[string]interface{}{"$in": []interface{}{1, 2}}},
+			false,
+		},
+		{
+			"filter[address.city][contains]=lon",
+			map[string]interface{}{"address.city": map[string]interface{}{"$regex": "lon", "$options": "i"}},
+			false,
+		},
+		{
+			"filter[created][gt]=2018-01-02",
+			map[string]interface{}{"created": map[string]interface{}{"$gt": time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)}},
+			false,
+		},
+		{
+			"filter=adults",
+			map[string]interface{}{"age": map[string]interface{}{"$gt": 17}},
+			false,
+		},
+		{
+			"filter=age:42",
+			map[string]interface{}{"age": 42},
+			false,
+		},
+		{
+			"filter[unknown]=1",
+			nil,
+			true,
+		},
+		{
+			"filter[age]=abc",
+			nil,
+			true,
+		},
+		{
+			"filter[active][gt]=true",
+			nil,
+			true,
+		},
+		{
+			"filter[name][like]=John",
+			nil,
+			true,
+		},
+		{
+			"filter=unknown",
+			nil,
+			true,
+		},
+	}
+
+	for i, v := range testData {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			params, err := url.ParseQuery(v.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := parseListFilters(filtersTestStore, params)
+			if (err != nil) != v.isErr {
+				t.Fatalf("parseListFilters(%s) error: %v", v.in, err)
+			}
+
+			if !reflect.DeepEqual(res, v.res) {
+				t.Fatalf("parseListFilters(%s) => %v, expected: %v", v.in, res, v.res)
+			}
+		})
+	}
+}
+
+func TestParseFieldsParam(t *testing.T) {
+	res, err := parseFieldsParam(filtersTestStore, "name,age", "-created")
+	if err != nil {
+		t.Fatalf("parseFieldsParam error: %v", err)
+	}
+
+	expected := []string{"name", "age", "_id", "created"}
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("parseFieldsParam => %v, expected: %v", res, expected)
+	}
+
+	if _, err := parseFieldsParam(filtersTestStore, "name,password", ""); err == nil {
+		t.Fatal("parseFieldsParam with unknown field must return error")
+	}
+}
@@ -0,0 +1,785 @@
+package internet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+	"github.com/getblank/uuid"
+)
+
+const graphQLURI = "/graphql"
+
+type gqlLoaderKeyType struct{}
+
+var (
+	gqlSchema       *graphql.Schema
+	gqlSchemaLocker sync.RWMutex
+	gqlLoaderKey    = gqlLoaderKeyType{}
+	gqlNameRGX      = regexp.MustCompile(`[^_0-9A-Za-z]`)
+
+	errGraphQLNotReady = errors.New("GraphQL schema is not ready yet")
+	errGraphQLNoQuery  = errors.New("query is required")
+
+	gqlJSON = graphql.NewScalar(graphql.ScalarConfig{
+		Name:         "JSON",
+		Description:  "Arbitrary JSON value",
+		Serialize:    func(v interface{}) interface{} { return v },
+		ParseValue:   func(v interface{}) interface{} { return v },
+		ParseLiteral: gqlParseLiteral,
+	})
+
+	gqlDateTime = graphql.NewScalar(graphql.ScalarConfig{
+		Name:         "DateTime",
+		Description:  "RFC 3339 date and time",
+		Serialize:    func(v interface{}) interface{} { return v },
+		ParseValue:   func(v interface{}) interface{} { return v },
+		ParseLiteral: gqlParseLiteral,
+	})
+)
+
+// graphQLRequest is the body of GraphQL POST request.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// Extensions makes structured errors visible in GraphQL errors.
+func (e *apiError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"status": e.Status}
+	if len(e.Code) > 0 {
+		ext["code"] = e.Code
+	}
+
+	if len(e.Fields) > 0 {
+		ext["errors"] = e.Fields
+	}
+
+	return ext
+}
+
+func initGraphQLRoutes() {
+	gr := r.With(allowAnyOriginMiddleware, jwtAuthMiddleware(true))
+	gr.Get(graphQLURI, graphQLHandler)
+	gr.Post(graphQLURI, idempotencyHandler(graphQLHandler))
+}
+
+// buildGraphQLSchema generates schema from the stores config. It is called on every config update.
+func buildGraphQLSchema(stores []config.Store) {
+	b := &gqlSchemaBuilder{stores: map[string]config.Store{}, types: map[string]*graphql.Object{}}
+	for _, store := range stores {
+		b.stores[store.Store] = store
+	}
+
+	schema, err := b.build()
+	if err != nil {
+		log.Errorf("Can't build GraphQL schema, error: %v", err)
+		return
+	}
+
+	gqlSchemaLocker.Lock()
+	gqlSchema = schema
+	gqlSchemaLocker.Unlock()
+	log.Info("GraphQL schema building complete")
+}
+
+func getGraphQLSchema() *graphql.Schema {
+	gqlSchemaLocker.RLock()
+	defer gqlSchemaLocker.RUnlock()
+
+	return gqlSchema
+}
+
+func graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	schema := getGraphQLSchema()
+	if schema == nil {
+		errorResponse(w, r, http.StatusServiceUnavailable, errGraphQLNotReady)
+		return
+	}
+
+	var req graphQLRequest
+	if r.Method == http.MethodGet {
+		params := r.URL.Query()
+		req.Query = params.Get("query")
+		req.OperationName = params.Get("operationName")
+		if v := params.Get("variables"); len(v) > 0 {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				errorResponse(w, r, http.StatusBadRequest, err)
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(req.Query) == 0 {
+		errorResponse(w, r, http.StatusBadRequest, errGraphQLNoQuery)
+		return
+	}
+
+	c, _ := r.Context().Value(credKey).(credentials)
+	ctx := context.WithValue(r.Context(), gqlLoaderKey, newGQLRefLoader(c))
+	params := graphql.Params{
+		Schema:         *schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	}
+
+	if gqlOperationType(req.Query, req.OperationName) == ast.OperationTypeSubscription {
+		graphQLSubscriptionHandler(w, r, params)
+		return
+	}
+
+	jsonResponse(w, graphql.Do(params))
+}
+
+// graphQLSubscriptionHandler streams subscription results as server-sent events.
+func graphQLSubscriptionHandler(w http.ResponseWriter, r *http.Request, params graphql.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	w.Header().Set(headerContentType, textEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for res := range graphql.Subscribe(params) {
+		encoded, err := json.Marshal(res)
+		if err != nil {
+			log.Errorf("[graphQLSubscriptionHandler] can't marshal result, error: %v", err)
+			return
+		}
+
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", encoded); err != nil {
+			log.Debugf("[graphQLSubscriptionHandler] write error: %v", err)
+			return
+		}
+
+		flusher.Flush()
+	}
+}
+
+func gqlOperationType(query, operationName string) string {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return ""
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		if len(operationName) == 0 || op.Name != nil && op.Name.Value == operationName {
+			return op.Operation
+		}
+	}
+
+	return ""
+}
+
+func gqlParseLiteral(valueAST ast.Value) interface{} {
+	switch v := valueAST.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.EnumValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.IntValue:
+		i, _ := strconv.Atoi(v.Value)
+		return i
+	case *ast.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.ListValue:
+		res := make([]interface{}, len(v.Values))
+		for i, item := range v.Values {
+			res[i] = gqlParseLiteral(item)
+		}
+
+		return res
+	case *ast.ObjectValue:
+		res := make(map[string]interface{}, len(v.Fields))
+		for _, f := range v.Fields {
+			res[f.Name.Value] = gqlParseLiteral(f.Value)
+		}
+
+		return res
+	}
+
+	return nil
+}
+
+// gqlName converts store or prop name to the valid GraphQL name.
+func gqlName(name string) string {
+	res := gqlNameRGX.ReplaceAllString(name, "_")
+	if len(res) == 0 || res[0] >= '0' && res[0] <= '9' {
+		res = "_" + res
+	}
+
+	return res
+}
+
+type gqlSchemaBuilder struct {
+	stores map[string]config.Store
+	types  map[string]*graphql.Object
+}
+
+func (b *gqlSchemaBuilder) build() (*graphql.Schema, error) {
+	names := make([]string, 0, len(b.stores))
+	for name := range b.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	query := graphql.Fields{}
+	mutation := graphql.Fields{}
+	subscription := graphql.Fields{}
+	for _, name := range names {
+		store := b.stores[name]
+		b.addStoreFields(store, query, mutation, subscription)
+	}
+
+	if len(query) == 0 {
+		return nil, errors.New("no stores available for GraphQL")
+	}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:        graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: query}),
+		Mutation:     graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutation}),
+		Subscription: graphql.NewObject(graphql.ObjectConfig{Name: "Subscription", Fields: subscription}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
+
+func (b *gqlSchemaBuilder) addStoreFields(store config.Store, query, mutation, subscription graphql.Fields) {
+	name := gqlName(store.Store)
+	storeType := b.storeType(store.Store)
+	idType := b.idType(store)
+	page := graphql.NewObject(graphql.ObjectConfig{
+		Name: name + "_page",
+		Fields: graphql.Fields{
+			"items": &graphql.Field{Type: graphql.NewList(storeType)},
+			"count": &graphql.Field{Type: graphql.Int},
+		},
+	})
+
+	query[name] = &graphql.Field{
+		Type:        storeType,
+		Description: fmt.Sprintf("Item of store %s", store.Store),
+		Args:        graphql.FieldConfigArgument{"_id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(idType)}},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return gqlTask(p.Context, taskq.DbGet, store.Store, map[string]interface{}{"_id": p.Args["_id"]})
+		},
+	}
+
+	query[name+"_list"] = &graphql.Field{
+		Type:        page,
+		Description: fmt.Sprintf("List of items of store %s", store.Store),
+		Args: graphql.FieldConfigArgument{
+			"query":   &graphql.ArgumentConfig{Type: gqlJSON},
+			"orderBy": &graphql.ArgumentConfig{Type: graphql.String},
+			"skip":    &graphql.ArgumentConfig{Type: graphql.Int},
+			"take":    &graphql.ArgumentConfig{Type: graphql.Int},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			s := getStoreSettings(store.Store)
+			take, _ := p.Args["take"].(int)
+			if take <= 0 {
+				take = s.DefaultPageSize
+			}
+
+			if take > s.MaxPageSize {
+				take = s.MaxPageSize
+			}
+
+			orderBy, _ := p.Args["orderBy"].(string)
+			if len(orderBy) == 0 {
+				orderBy = defaultOrderBy(store)
+			}
+
+			findQuery := map[string]interface{}{"query": p.Args["query"], "skip": p.Args["skip"], "take": take, "orderBy": orderBy}
+			return gqlTask(p.Context, taskq.DbFind, store.Store, map[string]interface{}{"query": findQuery})
+		},
+	}
+
+	if len(store.Widgets) > 0 {
+		query[name+"_widget"] = &graphql.Field{
+			Type:        gqlJSON,
+			Description: fmt.Sprintf("Widget data of store %s", store.Store),
+			Args: graphql.FieldConfigArgument{
+				"widgetId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"itemId":   &graphql.ArgumentConfig{Type: idType},
+				"data":     &graphql.ArgumentConfig{Type: gqlJSON},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return gqlTask(p.Context, taskq.WidgetData, store.Store, map[string]interface{}{
+					"widgetId": p.Args["widgetId"],
+					"itemId":   p.Args["itemId"],
+					"data":     p.Args["data"],
+				})
+			},
+		}
+	}
+
+	mutation[name+"_save"] = &graphql.Field{
+		Type:        storeType,
+		Description: fmt.Sprintf("Creates or updates item of store %s", store.Store),
+		Args:        graphql.FieldConfigArgument{"item": &graphql.ArgumentConfig{Type: graphql.NewNonNull(gqlJSON)}},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			item, ok := p.Args["item"].(map[string]interface{})
+			if !ok {
+				return nil, newAPIError(http.StatusBadRequest, "invalid_arguments", "item must be an object")
+			}
+
+			if err := gqlRequireAuth(p.Context); err != nil {
+				return nil, err
+			}
+
+			// the same validation as in the REST POST
+			if err := validateStoreItem(store.Store, item, false); err != nil {
+				return nil, toAPIError(0, err)
+			}
+
+			if item["_id"] == nil {
+				item["_id"] = uuid.NewV4()
+			}
+
+			return gqlTask(p.Context, taskq.DbSet, store.Store, map[string]interface{}{"item": item})
+		},
+	}
+
+	mutation[name+"_delete"] = &graphql.Field{
+		Type:        graphql.Boolean,
+		Description: fmt.Sprintf("Deletes item of store %s", store.Store),
+		Args:        graphql.FieldConfigArgument{"_id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(idType)}},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if err := gqlRequireAuth(p.Context); err != nil {
+				return false, err
+			}
+
+			if _, err := gqlTask(p.Context, taskq.DbDelete, store.Store, map[string]interface{}{"_id": p.Args["_id"]}); err != nil {
+				return false, err
+			}
+
+			return true, nil
+		},
+	}
+
+	for _, a := range append(append([]config.Action{}, store.Actions...), store.StoreActions...) {
+		actionID := a.ID
+		fieldName := name + "_action_" + gqlName(actionID)
+		if _, ok := mutation[fieldName]; ok {
+			continue
+		}
+
+		mutation[fieldName] = &graphql.Field{
+			Type:        gqlJSON,
+			Description: fmt.Sprintf("Runs action %s of store %s", actionID, store.Store),
+			Args: graphql.FieldConfigArgument{
+				"_id":  &graphql.ArgumentConfig{Type: idType},
+				"data": &graphql.ArgumentConfig{Type: gqlJSON},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if err := gqlRequireAuth(p.Context); err != nil {
+					return nil, err
+				}
+
+				return gqlTask(p.Context, taskq.DbAction, store.Store, map[string]interface{}{
+					"itemId":   p.Args["_id"],
+					"actionId": actionID,
+					"data":     p.Args["data"],
+				})
+			},
+		}
+	}
+
+	subscription[name+"_changes"] = &graphql.Field{
+		Type:        gqlJSON,
+		Description: fmt.Sprintf("Changes of store %s", store.Store),
+		Args:        graphql.FieldConfigArgument{"query": &graphql.ArgumentConfig{Type: gqlJSON}},
+		Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+			return gqlSubscribe(p.Context, uriSubStores+"."+store.Store, p.Args["query"])
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source, nil
+		},
+	}
+}
+
+func (b *gqlSchemaBuilder) idType(store config.Store) graphql.Input {
+	if store.Props["_id"].Type == config.PropInt {
+		return graphql.Int
+	}
+
+	return graphql.String
+}
+
+// storeType returns object type of the store. Fields are built lazily because refs can be cyclic.
+func (b *gqlSchemaBuilder) storeType(storeName string) *graphql.Object {
+	if t, ok := b.types[storeName]; ok {
+		return t
+	}
+
+	store := b.stores[storeName]
+	name := gqlName(storeName)
+	t := graphql.NewObject(graphql.ObjectConfig{
+		Name:        name,
+		Description: store.Label,
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := b.propFields(name, storeName, store.Props)
+			if _, ok := fields["_id"]; !ok {
+				fields["_id"] = &graphql.Field{Type: gqlJSON, Resolve: gqlPropResolver("_id")}
+			}
+
+			return fields
+		}),
+	})
+	b.types[storeName] = t
+
+	return t
+}
+
+// propFields returns fields of the store type or nested object type. storeName is empty for nested objects.
+func (b *gqlSchemaBuilder) propFields(typeName, storeName string, props map[string]config.Prop) graphql.Fields {
+	fields := graphql.Fields{}
+	for propName, p := range props {
+		fieldName := gqlName(propName)
+		if _, ok := fields[fieldName]; ok {
+			continue
+		}
+
+		if f := b.propField(typeName, storeName, propName, p); f != nil {
+			fields[fieldName] = f
+		}
+	}
+
+	return fields
+}
+
+func (b *gqlSchemaBuilder) propField(typeName, storeName, propName string, p config.Prop) *graphql.Field {
+	f := &graphql.Field{Description: p.Label, Resolve: gqlPropResolver(propName)}
+	switch p.Type {
+	case config.PropInt:
+		f.Type = graphql.Int
+	case config.PropFloat:
+		f.Type = graphql.Float
+	case config.PropBool:
+		f.Type = graphql.Boolean
+	case config.PropString, config.PropUUID:
+		f.Type = graphql.String
+	case config.PropDate, config.PropDateOnly:
+		f.Type = gqlDateTime
+	case config.PropObject, config.PropObjectList:
+		if len(p.Props) == 0 {
+			f.Type = gqlJSON
+			break
+		}
+
+		nestedName := typeName + "_" + gqlName(propName)
+		var nested graphql.Output = graphql.NewObject(graphql.ObjectConfig{
+			Name:   nestedName,
+			Fields: b.propFields(nestedName, "", p.Props),
+		})
+		if p.Type == config.PropObjectList {
+			nested = graphql.NewList(nested)
+		}
+
+		f.Type = nested
+	case config.PropRef:
+		if _, ok := b.stores[p.Store]; !ok {
+			f.Type = gqlJSON
+			break
+		}
+
+		f.Type = b.storeType(p.Store)
+		f.Resolve = gqlRefResolver(propName, p.Store)
+	case config.PropRefList:
+		if _, ok := b.stores[p.Store]; !ok {
+			f.Type = gqlJSON
+			break
+		}
+
+		f.Type = graphql.NewList(b.storeType(p.Store))
+		f.Resolve = gqlRefListResolver(storeName, propName, p.Store)
+		if len(storeName) > 0 {
+			f.Args = graphql.FieldConfigArgument{
+				"query": &graphql.ArgumentConfig{Type: gqlJSON},
+				"skip":  &graphql.ArgumentConfig{Type: graphql.Int},
+				"take":  &graphql.ArgumentConfig{Type: graphql.Int},
+			}
+		}
+	case config.PropAny, config.PropVirtual, config.PropVirtualClient, config.PropFile, config.PropFileList, config.PropComments:
+		f.Type = gqlJSON
+	default:
+		// passwords, actions and widgets are not the part of item data
+		return nil
+	}
+
+	return f
+}
+
+func gqlPropResolver(propName string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		item, ok := p.Source.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+
+		return item[propName], nil
+	}
+}
+
+// gqlRefResolver resolves ref prop with batched loading of referenced items.
+func gqlRefResolver(propName, refStore string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		item, ok := p.Source.(map[string]interface{})
+		if !ok || item[propName] == nil {
+			return nil, nil
+		}
+
+		return gqlLoader(p.Context).load(refStore, item[propName]), nil
+	}
+}
+
+// gqlRefListResolver resolves refList prop. Without arguments referenced items are loaded in batches,
+// with query or paging arguments DbLoadRefs task is used.
+func gqlRefListResolver(storeName, propName, refStore string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		item, ok := p.Source.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+
+		if len(p.Args) > 0 {
+			query := map[string]interface{}{"query": p.Args["query"], "skip": p.Args["skip"], "take": p.Args["take"]}
+			res, err := gqlTask(p.Context, taskq.DbLoadRefs, storeName, map[string]interface{}{
+				"_id":      item["_id"],
+				"prop":     propName,
+				"selected": false,
+				"query":    query,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			if m, ok := res.(map[string]interface{}); ok {
+				return m["items"], nil
+			}
+
+			return res, nil
+		}
+
+		ids, _ := item[propName].([]interface{})
+		loader := gqlLoader(p.Context)
+		thunks := make([]func() (interface{}, error), len(ids))
+		for i, id := range ids {
+			thunks[i] = loader.load(refStore, id)
+		}
+
+		return func() (interface{}, error) {
+			res := make([]interface{}, 0, len(thunks))
+			for _, thunk := range thunks {
+				v, err := thunk()
+				if err != nil {
+					return nil, err
+				}
+
+				if v != nil {
+					res = append(res, v)
+				}
+			}
+
+			return res, nil
+		}, nil
+	}
+}
+
+// gqlRequireAuth returns error for guests. Queries are allowed for guests, but mutations require JWT as REST write routes do.
+func gqlRequireAuth(ctx context.Context) error {
+	if cred, _ := ctx.Value(credKey).(credentials); cred.claims == nil {
+		return newAPIError(http.StatusUnauthorized, "", "mutations require authentication")
+	}
+
+	return nil
+}
+
+func gqlCredentials(ctx context.Context) credentials {
+	c, _ := ctx.Value(credKey).(credentials)
+	if c.userID == nil {
+		c.userID = "guest"
+	}
+
+	return c
+}
+
+func gqlTask(ctx context.Context, typ, storeName string, args map[string]interface{}) (interface{}, error) {
+	cred := gqlCredentials(ctx)
+	t := taskq.Task{
+		Type:      typ,
+		UserID:    cred.userID,
+		Store:     storeName,
+		Arguments: args,
+	}
+	if cred.claims != nil {
+		t.Arguments["tokenInfo"] = cred.claims.toMap()
+	}
+
+	res, err := pushTask(ctx, &t)
+	if err != nil {
+		return nil, toAPIError(0, err)
+	}
+
+	return res, nil
+}
+
+// gqlSubscribe registers SSE subscription for the uri and returns channel of events for graphql.Subscribe.
+func gqlSubscribe(ctx context.Context, uri string, extra interface{}) (interface{}, error) {
+	cred := gqlCredentials(ctx)
+	if len(cred.sessionID) == 0 {
+		return nil, newAPIError(http.StatusUnauthorized, "", "subscriptions require authentication")
+	}
+
+	s := &sseStream{events: make(chan sseEvent, sseStreamBufferSize), closed: make(chan struct{})}
+	connID, _, _, err := sseHub.subscribe(cred, uri, extra, s, 0, false)
+	if err != nil {
+		return nil, toAPIError(http.StatusForbidden, err)
+	}
+
+	res := make(chan interface{})
+	go func() {
+		defer close(res)
+		defer sseHub.unsubscribe(connID, s)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.closed:
+				return
+			case e := <-s.events:
+				var event interface{}
+				if err := json.Unmarshal(e.data, &event); err != nil {
+					log.Errorf("[gqlSubscribe] can't unmarshal event, error: %v", err)
+					continue
+				}
+
+				select {
+				case res <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return res, nil
+}
+
+// gqlRefLoader collects ids of refs requested on the same level of the query and loads them with a single DbFind.
+type gqlRefLoader struct {
+	cred    credentials
+	pending map[string][]interface{}
+	loaded  map[string]map[string]interface{}
+	errs    map[string]error
+	sync.Mutex
+}
+
+func newGQLRefLoader(cred credentials) *gqlRefLoader {
+	return &gqlRefLoader{
+		cred:    cred,
+		pending: map[string][]interface{}{},
+		loaded:  map[string]map[string]interface{}{},
+		errs:    map[string]error{},
+	}
+}
+
+func gqlLoader(ctx context.Context) *gqlRefLoader {
+	if l, ok := ctx.Value(gqlLoaderKey).(*gqlRefLoader); ok {
+		return l
+	}
+
+	return newGQLRefLoader(gqlCredentials(ctx))
+}
+
+func (l *gqlRefLoader) load(storeName string, id interface{}) func() (interface{}, error) {
+	key := fmt.Sprint(id)
+	l.Lock()
+	if _, ok := l.loaded[storeName][key]; !ok {
+		l.pending[storeName] = append(l.pending[storeName], id)
+	}
+	l.Unlock()
+
+	return func() (interface{}, error) {
+		l.Lock()
+		defer l.Unlock()
+
+		if ids := l.pending[storeName]; len(ids) > 0 {
+			delete(l.pending, storeName)
+			l.fetch(storeName, ids)
+		}
+
+		if err := l.errs[storeName]; err != nil {
+			return nil, err
+		}
+
+		return l.loaded[storeName][key], nil
+	}
+}
+
+func (l *gqlRefLoader) fetch(storeName string, ids []interface{}) {
+	if l.loaded[storeName] == nil {
+		l.loaded[storeName] = map[string]interface{}{}
+	}
+
+	findQuery := map[string]interface{}{
+		"query": map[string]interface{}{"_id": map[string]interface{}{"$in": ids}},
+		"take":  len(ids),
+	}
+	ctx := context.WithValue(context.Background(), credKey, l.cred)
+	res, err := gqlTask(ctx, taskq.DbFind, storeName, map[string]interface{}{"query": findQuery})
+	if err != nil {
+		l.errs[storeName] = err
+		return
+	}
+
+	result, _ := res.(map[string]interface{})
+	items, _ := result["items"].([]interface{})
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			l.loaded[storeName][fmt.Sprint(m["_id"])] = m
+		}
+	}
+
+	// missing items are not requested again
+	for _, id := range ids {
+		key := fmt.Sprint(id)
+		if _, ok := l.loaded[storeName][key]; !ok {
+			l.loaded[storeName][key] = nil
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package internet
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+func TestBuildGraphQLSchema(t *testing.T) {
+	orders := config.Store{
+		Store: "orders-2",
+		Props: map[string]config.Prop{
+			"_id":      {Type: config.PropInt},
+			"user":     {Type: config.PropRef, Store: "users"},
+			"items":    {Type: config.PropObjectList, Props: map[string]config.Prop{"sku": {Type: config.PropString}}},
+			"password": {Type: config.PropPassword},
+		},
+		Actions: []config.Action{{ID: "approve"}},
+	}
+	users := filtersTestStore
+	users.Props = map[string]config.Prop{}
+	for k, v := range filtersTestStore.Props {
+		users.Props[k] = v
+	}
+	users.Props["orders"] = config.Prop{Type: config.PropRefList, Store: "orders-2"}
+
+	b := &gqlSchemaBuilder{stores: map[string]config.Store{"users": users, "orders-2": orders}, types: map[string]*graphql.Object{}}
+	schema, err := b.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fieldNames := func(typeName string) []string {
+		res := graphql.Do(graphql.Params{
+			Schema:         *schema,
+			RequestString:  `query($name: String!) { __type(name: $name) { fields { name } } }`,
+			VariableValues: map[string]interface{}{"name": typeName},
+		})
+		if len(res.Errors) > 0 {
+			t.Fatal(res.Errors)
+		}
+
+		var names []string
+		typ := res.Data.(map[string]interface{})["__type"].(map[string]interface{})
+		for _, f := range typ["fields"].([]interface{}) {
+			names = append(names, f.(map[string]interface{})["name"].(string))
+		}
+		sort.Strings(names)
+
+		return names
+	}
+
+	testData := []struct {
+		typeName string
+		fields   []string
+	}{
+		{"orders_2", []string{"_id", "items", "user"}},
+		{"orders_2_items", []string{"sku"}},
+		{"users", []string{"_id", "active", "address", "age", "created", "name", "orders"}},
+		{"Query", []string{"orders_2", "orders_2_list", "users", "users_list"}},
+		{"Mutation", []string{"orders_2_action_approve", "orders_2_delete", "orders_2_save", "users_delete", "users_save"}},
+		{"Subscription", []string{"orders_2_changes", "users_changes"}},
+	}
+	for _, v := range testData {
+		fields := fieldNames(v.typeName)
+		if len(fields) != len(v.fields) {
+			t.Fatalf("%s: expected fields %v, got %v", v.typeName, v.fields, fields)
+		}
+
+		for i := range fields {
+			if fields[i] != v.fields[i] {
+				t.Fatalf("%s: expected fields %v, got %v", v.typeName, v.fields, fields)
+			}
+		}
+	}
+}
+
+func TestGraphQLMutationsRequireAuth(t *testing.T) {
+	b := &gqlSchemaBuilder{stores: map[string]config.Store{"users": filtersTestStore}, types: map[string]*graphql.Object{}}
+	schema, err := b.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []string{
+		`mutation { users_save(item: {name: "John"}) { _id } }`,
+		`mutation { users_delete(_id: "1") }`,
+	} {
+		res := graphql.Do(graphql.Params{
+			Schema:        *schema,
+			RequestString: q,
+			Context:       context.WithValue(context.Background(), credKey, credentials{userID: "guest"}),
+		})
+		if len(res.Errors) != 1 || res.Errors[0].Message != "mutations require authentication" {
+			t.Fatalf("%s: expected authentication error, got %v", q, res.Errors)
+		}
+	}
+}
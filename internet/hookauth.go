@@ -0,0 +1,142 @@
+package internet
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getblank/blank-router/taskq"
+
+	"github.com/getblank/blank-one/sessions"
+)
+
+const (
+	hookAuthNone   = "none"
+	hookAuthJWT    = "jwt"
+	hookAuthAPIKey = "apiKey"
+	hookAuthBasic  = "basic"
+
+	defaultAPIKeyHeader = "X-Api-Key"
+	hookRootUserID      = "root"
+)
+
+// hookAuth sets authentication of the http hook, e.g.:
+//
+//	"hooks": {"/orders": {"auth": {"mode": "jwt", "required": true}}}
+//
+// Modes:
+//
+//	none    hook runs as root, it is the default
+//	jwt     the same token as for REST API, without token hook runs as guest if it is not required
+//	apiKey  session api key in the header, X-Api-Key by default
+//	basic   HTTP Basic login and password of the user
+type hookAuth struct {
+	Mode     string `json:"mode,omitempty"`
+	Required bool   `json:"required,omitempty"`
+	Header   string `json:"header,omitempty"`
+}
+
+// hookAuthHandler authenticates hook requests by the hook settings and puts credentials to the request context.
+func hookAuthHandler(storeName, hookURI string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := getStoreSettings(storeName).Hooks[hookURI].Auth
+		if a == nil {
+			next(w, r)
+			return
+		}
+
+		switch a.Mode {
+		case "", hookAuthNone:
+			next(w, r)
+		case hookAuthJWT:
+			jwtAuthMiddleware(!a.Required)(next).ServeHTTP(w, r)
+		case hookAuthAPIKey:
+			apiKeyAuthHandler(a, next)(w, r)
+		case hookAuthBasic:
+			basicAuthHandler(next)(w, r)
+		default:
+			log.Errorf("Unknown auth mode %q of hook %s for store %s", a.Mode, hookURI, storeName)
+			errorResponse(w, r, http.StatusInternalServerError, nil)
+		}
+	}
+}
+
+// apiKeyAuthHandler authenticates request by the session api key. Credentials are taken from the session JWT.
+// Key is accepted in the header only, as URLs with query params are written to the logs.
+func apiKeyAuthHandler(a *hookAuth, next http.HandlerFunc) http.HandlerFunc {
+	header := a.Header
+	if len(header) == 0 {
+		header = defaultAPIKeyHeader
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get(header)
+		if len(apiKey) == 0 {
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		accessToken, err := sessions.AccessToken(apiKey)
+		if err != nil {
+			errorResponse(w, r, http.StatusUnauthorized, ErrSessionNotFound)
+			return
+		}
+
+		claims, err := extractClaimsFromJWT(accessToken)
+		if err != nil {
+			errorResponse(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), credKey, credentials{userID: claims.UserID, sessionID: claims.SessionID, claims: claims})
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// basicAuthHandler authenticates request by the login and password with the same task as the login handler.
+// Session is not created, tokenInfo is made from the user returned by the workers.
+func basicAuthHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		login, password, ok := r.BasicAuth()
+		if !ok || len(login) == 0 || len(password) == 0 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="blank", charset="UTF-8"`)
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		t := taskq.Task{
+			Type:      taskq.Auth,
+			Arguments: map[string]interface{}{"login": login, "password": password},
+		}
+
+		res, err := pushTask(r.Context(), &t)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="blank", charset="UTF-8"`)
+			errorResponse(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		user, ok := res.(map[string]interface{})
+		if !ok || user["_id"] == nil {
+			log.Warn("Invalid type of result on hook basic auth")
+			errorResponse(w, r, http.StatusInternalServerError, nil)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), credKey, credentials{userID: user["_id"], claims: &blankClaims{UserID: user["_id"], Extra: user}})
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// hookCredentials returns user id and tokenInfo of the hook task. Hooks without authentication run as root.
+func hookCredentials(r *http.Request) (interface{}, map[string]interface{}) {
+	cred, ok := r.Context().Value(credKey).(credentials)
+	if !ok {
+		return hookRootUserID, nil
+	}
+
+	if cred.claims == nil {
+		return cred.userID, nil
+	}
+
+	return cred.userID, cred.claims.toMap()
+}
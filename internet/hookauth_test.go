@@ -0,0 +1,70 @@
+package internet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHookAuthHandler(t *testing.T) {
+	settingsLocker.Lock()
+	settings["hookAuthTestStore"] = storeSettings{Hooks: map[string]hookSettings{
+		"/none":    {Auth: &hookAuth{Mode: hookAuthNone}},
+		"/jwt":     {Auth: &hookAuth{Mode: hookAuthJWT, Required: true}},
+		"/guest":   {Auth: &hookAuth{Mode: hookAuthJWT}},
+		"/apiKey":  {Auth: &hookAuth{Mode: hookAuthAPIKey}},
+		"/basic":   {Auth: &hookAuth{Mode: hookAuthBasic}},
+		"/unknown": {Auth: &hookAuth{Mode: "oauth"}},
+	}}
+	settingsLocker.Unlock()
+	defer func() {
+		settingsLocker.Lock()
+		delete(settings, "hookAuthTestStore")
+		settingsLocker.Unlock()
+	}()
+
+	testData := []struct {
+		uri    string
+		status int
+		userID interface{}
+	}{
+		{"/notConfigured", http.StatusOK, hookRootUserID},
+		{"/none", http.StatusOK, hookRootUserID},
+		{"/jwt", http.StatusUnauthorized, nil},
+		{"/guest", http.StatusOK, "guest"},
+		{"/apiKey", http.StatusUnauthorized, nil},
+		{"/basic", http.StatusUnauthorized, nil},
+		{"/unknown", http.StatusInternalServerError, nil},
+	}
+
+	for _, v := range testData {
+		t.Run(v.uri, func(t *testing.T) {
+			var userID interface{}
+			handler := hookAuthHandler("hookAuthTestStore", v.uri, func(w http.ResponseWriter, r *http.Request) {
+				userID, _ = hookCredentials(r)
+			})
+
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, "/hooks/hookAuthTestStore"+v.uri, nil))
+			if rec.Code != v.status {
+				t.Fatalf("status %d, expected: %d", rec.Code, v.status)
+			}
+
+			if userID != v.userID {
+				t.Fatalf("hook user %v, expected: %v", userID, v.userID)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthIgnoresQueryParam(t *testing.T) {
+	handler := apiKeyAuthHandler(&hookAuth{Mode: hookAuthAPIKey}, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("hook was called without api key header")
+	})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/hooks/hookAuthTestStore/apiKey?apiKey=secret", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, expected: %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,234 @@
+package internet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signaturePresetGitHub = "github"
+	signaturePresetStripe = "stripe"
+
+	// defaultSignatureTolerance is the max age of the signed timestamp if tolerance is not set
+	defaultSignatureTolerance = 5 * time.Minute
+)
+
+var (
+	signedHookMaxBodySize int64 = 10 << 20
+
+	errSignatureMissing   = newAPIError(http.StatusUnauthorized, "invalid_signature", "request signature is missing")
+	errSignatureInvalid   = newAPIError(http.StatusUnauthorized, "invalid_signature", "request signature is invalid")
+	errSignatureTimestamp = newAPIError(http.StatusUnauthorized, "invalid_signature", "request timestamp is out of tolerance")
+)
+
+// hookSettings are settings of the http hook. They are set in the "hooks" store settings by the hook uri
+// as it is in the config, e.g.:
+//
+//	"hooks": {"/stripe": {"signature": {"preset": "stripe", "secretEnv": "STRIPE_WEBHOOK_SECRET"}}}
+type hookSettings struct {
+	Signature *hookSignature `json:"signature,omitempty"`
+	Auth      *hookAuth      `json:"auth,omitempty"`
+}
+
+// hookSignature describes HMAC signature of the hook requests. Signature is computed from the raw body,
+// or from "<timestamp>.<body>" if TimestampHeader is set. Signed timestamps older than Tolerance, 5m by default,
+// are rejected. Presets:
+//
+//	github  X-Hub-Signature-256: sha256=<hex>
+//	stripe  Stripe-Signature: t=<timestamp>,v1=<hex>
+type hookSignature struct {
+	Preset          string `json:"preset,omitempty"`
+	Secret          string `json:"secret,omitempty"`
+	SecretEnv       string `json:"secretEnv,omitempty"` // name of the environment variable with the secret
+	Header          string `json:"header,omitempty"`
+	Algorithm       string `json:"algorithm,omitempty"` // sha1, sha256 (default) or sha512
+	Encoding        string `json:"encoding,omitempty"`  // hex (default) or base64
+	Prefix          string `json:"prefix,omitempty"`    // prefix of the signature in the header, e.g. "sha256="
+	TimestampHeader string `json:"timestampHeader,omitempty"`
+	Tolerance       string `json:"tolerance,omitempty"` // max age of the timestamp, e.g. "5m"
+}
+
+func (s *hookSignature) secret() string {
+	if len(s.SecretEnv) > 0 {
+		return os.Getenv(s.SecretEnv)
+	}
+
+	return s.Secret
+}
+
+func (s *hookSignature) hash() (func() hash.Hash, error) {
+	switch strings.ToLower(s.Algorithm) {
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "sha512":
+		return sha512.New, nil
+	}
+
+	return nil, fmt.Errorf("unknown signature algorithm %q", s.Algorithm)
+}
+
+func (s *hookSignature) tolerance() (time.Duration, error) {
+	if len(s.Tolerance) == 0 {
+		return defaultSignatureTolerance, nil
+	}
+
+	d, err := time.ParseDuration(s.Tolerance)
+	if err == nil && d <= 0 {
+		return 0, fmt.Errorf("invalid signature tolerance %q", s.Tolerance)
+	}
+
+	return d, err
+}
+
+// verify checks signature of the request body.
+func (s *hookSignature) verify(header http.Header, body []byte, now time.Time) error {
+	secret := s.secret()
+	if len(secret) == 0 {
+		return errors.New("signature secret is not configured")
+	}
+
+	newHash, err := s.hash()
+	if err != nil {
+		return err
+	}
+
+	tolerance, err := s.tolerance()
+	if err != nil {
+		return err
+	}
+
+	var timestamp string
+	var signatures []string
+	switch s.Preset {
+	case signaturePresetGitHub:
+		if sig := header.Get("X-Hub-Signature-256"); len(sig) > 0 {
+			signatures = []string{strings.TrimPrefix(sig, "sha256=")}
+		}
+	case signaturePresetStripe:
+		for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			switch kv[0] {
+			case "t":
+				timestamp = kv[1]
+			case "v1":
+				signatures = append(signatures, kv[1])
+			}
+		}
+
+		if len(timestamp) == 0 {
+			return errSignatureMissing
+		}
+	case "":
+		if sig := header.Get(s.Header); len(sig) > 0 {
+			signatures = []string{strings.TrimPrefix(sig, s.Prefix)}
+		}
+
+		if len(s.TimestampHeader) > 0 {
+			if timestamp = header.Get(s.TimestampHeader); len(timestamp) == 0 {
+				return errSignatureMissing
+			}
+		}
+	default:
+		return fmt.Errorf("unknown signature preset %q", s.Preset)
+	}
+
+	if len(signatures) == 0 {
+		return errSignatureMissing
+	}
+
+	payload := body
+	if len(timestamp) > 0 {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || math.Abs(now.Sub(time.Unix(ts, 0)).Seconds()) > tolerance.Seconds() {
+			return errSignatureTimestamp
+		}
+
+		payload = append([]byte(timestamp+"."), body...)
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	for _, sig := range signatures {
+		var decoded []byte
+		var err error
+		if strings.ToLower(s.Encoding) == "base64" {
+			decoded, err = base64.StdEncoding.DecodeString(sig)
+		} else {
+			decoded, err = hex.DecodeString(sig)
+		}
+
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+
+	return errSignatureInvalid
+}
+
+// hookSignatureHandler verifies signature of the hook requests if it is set in the hook settings.
+// Settings are taken on every request, so they can be changed without restart.
+// Bodies of signed hooks are read before verification, so they are limited by signedHookMaxBodySize.
+func hookSignatureHandler(storeName, hookURI string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := getStoreSettings(storeName).Hooks[hookURI].Signature
+		if s == nil {
+			next(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, signedHookMaxBodySize))
+		if err != nil {
+			if int64(len(body)) == signedHookMaxBodySize {
+				errorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Errorf("request body is too large, max size is %d bytes", signedHookMaxBodySize))
+				return
+			}
+
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if err := s.verify(r.Header, body, time.Now()); err != nil {
+			if _, ok := err.(*apiError); !ok {
+				log.Errorf("Can't verify signature of hook %s for store %s, error: %v", hookURI, storeName, err)
+				errorResponse(w, r, http.StatusInternalServerError, nil)
+				return
+			}
+
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func init() {
+	if s := os.Getenv("BLANK_SIGNED_HOOK_MAX_BODY_SIZE"); len(s) > 0 {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+			signedHookMaxBodySize = n
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package internet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hmacSHA256(secret, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func TestHookSignatureVerify(t *testing.T) {
+	body := []byte(`{"event":"paid"}`)
+	now := time.Unix(1600000000, 0)
+	ts := fmt.Sprint(now.Unix())
+	oldTS := fmt.Sprint(now.Add(-time.Hour).Unix())
+
+	testData := []struct {
+		name     string
+		sig      hookSignature
+		header   http.Header
+		expected error
+	}{
+		{
+			"github",
+			hookSignature{Preset: signaturePresetGitHub, Secret: "s"},
+			http.Header{"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(hmacSHA256("s", string(body)))}},
+			nil,
+		},
+		{
+			"github wrong secret",
+			hookSignature{Preset: signaturePresetGitHub, Secret: "s"},
+			http.Header{"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(hmacSHA256("other", string(body)))}},
+			errSignatureInvalid,
+		},
+		{
+			"github no header",
+			hookSignature{Preset: signaturePresetGitHub, Secret: "s"},
+			http.Header{},
+			errSignatureMissing,
+		},
+		{
+			"stripe",
+			hookSignature{Preset: signaturePresetStripe, Secret: "s"},
+			http.Header{"Stripe-Signature": {"t=" + ts + ",v1=bad,v1=" + hex.EncodeToString(hmacSHA256("s", ts+"."+string(body)))}},
+			nil,
+		},
+		{
+			"stripe expired",
+			hookSignature{Preset: signaturePresetStripe, Secret: "s"},
+			http.Header{"Stripe-Signature": {"t=" + oldTS + ",v1=" + hex.EncodeToString(hmacSHA256("s", oldTS+"."+string(body)))}},
+			errSignatureTimestamp,
+		},
+		{
+			"custom base64 with timestamp",
+			hookSignature{Secret: "s", Header: "X-Signature", Encoding: "base64", TimestampHeader: "X-Timestamp", Tolerance: "1m"},
+			http.Header{"X-Signature": {base64.StdEncoding.EncodeToString(hmacSHA256("s", ts+"."+string(body)))}, "X-Timestamp": {ts}},
+			nil,
+		},
+		{
+			"custom timestamp expired with default tolerance",
+			hookSignature{Secret: "s", Header: "X-Signature", TimestampHeader: "X-Timestamp"},
+			http.Header{"X-Signature": {hex.EncodeToString(hmacSHA256("s", oldTS+"."+string(body)))}, "X-Timestamp": {oldTS}},
+			errSignatureTimestamp,
+		},
+	}
+
+	for _, v := range testData {
+		t.Run(v.name, func(t *testing.T) {
+			if err := v.sig.verify(v.header, body, now); err != v.expected {
+				t.Fatalf("verify => %v, expected: %v", err, v.expected)
+			}
+		})
+	}
+
+	if err := (&hookSignature{Preset: signaturePresetGitHub}).verify(http.Header{}, body, now); err == nil {
+		t.Error("signature without secret was verified")
+	}
+}
+
+func TestHookSignatureHandlerBodyLimit(t *testing.T) {
+	defer func(size int64) { signedHookMaxBodySize = size }(signedHookMaxBodySize)
+	signedHookMaxBodySize = 8
+
+	settingsLocker.Lock()
+	settings["hookSigTestStore"] = storeSettings{Hooks: map[string]hookSettings{"/paid": {Signature: &hookSignature{Preset: signaturePresetGitHub, Secret: "s"}}}}
+	settingsLocker.Unlock()
+	defer func() {
+		settingsLocker.Lock()
+		delete(settings, "hookSigTestStore")
+		settingsLocker.Unlock()
+	}()
+
+	h := hookSignatureHandler("hookSigTestStore", "/paid", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("hook with too large body was called")
+	})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("POST", "/hooks/paid", strings.NewReader(`{"event":"paid"}`)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", w.Code)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"mime"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -26,6 +27,7 @@ const apiV1baseURI = "/api/v1/"
 var (
 	routesBuildingCompleted bool
 	paramConverterRGX       = regexp.MustCompile(":([a-zA-Z]+[a-zA-Z0-9]*)")
+	errUnknownEncoding      = errors.New("unknown encoding type")
 )
 
 type result struct {
@@ -38,11 +40,14 @@ type result struct {
 	FilePath string            `json:"filePath"`
 	Store    string            `json:"store"`
 	ID       string            `json:"_id"`
+	StreamID string            `json:"streamId"`
+	Format   string            `json:"format"`
 }
 
 func onConfigUpdate(c map[string]config.Store) {
 	wamp.Disconnect()
 	log.Info("New config arrived")
+	loadStoreSettings(c)
 	if routesBuildingCompleted {
 		log.Warn("Routes already built. Need to restart if http hooks or actions modified.")
 	}
@@ -64,6 +69,7 @@ func onConfigUpdate(c map[string]config.Store) {
 
 		group := r.Route(groupURI, nil)
 		for i, hook := range store.HTTPHooks {
+			configURI := hook.URI
 			hook.URI = convertHookURI(hook.URI)
 			if len(hook.URI) == 0 {
 				log.Error("Empty URI in hook", strconv.Itoa(i), " for "+groupURI+". Will ignored")
@@ -118,34 +124,45 @@ func onConfigUpdate(c map[string]config.Store) {
 
 			hookIndex := i
 			hookHandler := func(w http.ResponseWriter, r *http.Request) {
+				userID, tokenInfo := hookCredentials(r)
+				request, err := extractRequest(r, storeName)
+				if err != nil {
+					errorResponse(w, r, 0, err)
+					return
+				}
+
 				t := taskq.Task{
 					Store:  storeName,
 					Type:   taskq.HTTPHook,
-					UserID: "root",
+					UserID: userID,
 					Arguments: map[string]interface{}{
-						"request":   extractRequest(r),
+						"request":   request,
 						"hookIndex": hookIndex,
 					},
 				}
-				_res, err := taskq.PushAndGetResult(&t, 0)
+				if tokenInfo != nil {
+					t.Arguments["tokenInfo"] = tokenInfo
+				}
+				_res, err := pushTask(r.Context(), &t)
 				if err != nil {
-					errorResponse(w, http.StatusSeeOther, err)
+					removeTaskUploads(&t)
+					errorResponse(w, r, 0, err)
 					return
 				}
 
 				res, err := parseResult(_res)
 				if err != nil {
-					errorResponse(w, http.StatusInternalServerError, err)
+					errorResponse(w, r, http.StatusInternalServerError, err)
 					return
 				}
 
 				defaultResponse(w, r, res)
 			}
 
-			handler(hook.URI, hookHandler)
+			handler(hook.URI, hookSignatureHandler(storeName, configURI, hookAuthHandler(storeName, configURI, idempotencyHandler(hookHandler))))
 			log.Infof("Created '%s' httpHook for store '%s' with path %s", hook.Method, storeName, groupURI+hook.URI)
 			if lowerHandler != nil {
-				lowerHandler(hook.URI, hookHandler)
+				lowerHandler(hook.URI, hookSignatureHandler(storeName, configURI, hookAuthHandler(storeName, configURI, idempotencyHandler(hookHandler))))
 				log.Infof("Created '%s' httpHook on lower case for store '%s' with path %s", hook.Method, storeName, lowerGroupURI+hook.URI)
 			}
 		}
@@ -168,6 +185,8 @@ func onConfigUpdate(c map[string]config.Store) {
 
 	createRESTAPI(httpEnabledStores)
 	log.Info("REST API building complete")
+
+	buildGraphQLSchema(httpEnabledStores)
 }
 
 func createFileHandlers(storeName string) {
@@ -207,8 +226,7 @@ func writeFileFromFileStore(w http.ResponseWriter, storeName, fileID, fileName s
 	}
 
 	w.Header().Set(headerContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
-	body, _ := ioutil.ReadAll(res.Body)
-	if _, err := w.Write(body); err != nil {
+	if _, err := io.Copy(w, res.Body); err != nil {
 		log.Errorf("[writeFileFromFileStore] write error: %v", err)
 	}
 }
@@ -221,14 +239,20 @@ func createHTTPActions(storeName string, actions []config.Action) {
 			c := r.Context().Value(credKey)
 			if c == nil {
 				log.Warn("HTTP ACTION: no cred in echo context")
-				jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+				errorResponse(w, r, http.StatusUnauthorized, nil)
 				return
 			}
 
 			cred, ok := c.(credentials)
 			if !ok {
 				log.Warn("HTTP ACTION: invalid cred in echo context")
-				jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+				errorResponse(w, r, http.StatusUnauthorized, nil)
+				return
+			}
+
+			request, err := extractRequest(r, storeName)
+			if err != nil {
+				errorResponse(w, r, 0, err)
 				return
 			}
 
@@ -237,7 +261,7 @@ func createHTTPActions(storeName string, actions []config.Action) {
 				Type:   taskq.DbAction,
 				UserID: cred.userID,
 				Arguments: map[string]interface{}{
-					"request":  extractRequest(r),
+					"request":  request,
 					"actionId": actionID,
 				},
 			}
@@ -250,15 +274,21 @@ func createHTTPActions(storeName string, actions []config.Action) {
 				t.Arguments["itemId"] = itemID
 			}
 
-			_res, err := taskq.PushAndGetResult(&t, 0)
+			if async, preferred := isAsyncAction(r, storeName, actionID); async {
+				asyncActionResponse(w, r, cred, &t, preferred)
+				return
+			}
+
+			_res, err := pushTask(r.Context(), &t)
 			if err != nil {
-				errorResponse(w, http.StatusSeeOther, err)
+				removeTaskUploads(&t)
+				errorResponse(w, r, 0, err)
 				return
 			}
 
 			res, err := parseResult(_res)
 			if err != nil {
-				errorResponse(w, http.StatusInternalServerError, err)
+				errorResponse(w, r, http.StatusInternalServerError, err)
 				return
 			}
 
@@ -269,14 +299,16 @@ func createHTTPActions(storeName string, actions []config.Action) {
 		if v.Type == "http" {
 			r.With(jwtAuthMiddleware(false)).Get(path, handler)
 		} else {
-			r.With(jwtAuthMiddleware(false)).Post(path, handler)
+			r.With(jwtAuthMiddleware(false), idempotencyMiddleware).Post(path, handler)
 		}
 
 		log.Infof("Registered httpAction for store '%s' with path %s", storeName, path)
 	}
 }
 
-func extractRequest(r *http.Request) map[string]interface{} {
+// extractRequest returns the request for the hook or action task. Uploaded files are streamed to the file store
+// by the uploads settings of the store and passed to the task as references in "files".
+func extractRequest(r *http.Request, storeName string) (map[string]interface{}, error) {
 	routeCtx := chi.RouteContext(r.Context())
 	urlParams := routeCtx.URLParams
 	params := map[string]string{}
@@ -291,31 +323,67 @@ func extractRequest(r *http.Request) map[string]interface{} {
 		}
 	}
 
-	if err := r.ParseMultipartForm(1024); err != nil {
-		if err := r.ParseForm(); err != nil {
-			log.Debugf("[extractRequest] extract form data error: %v", err)
+	uploads := getStoreSettings(storeName).Uploads
+	files := []uploadedFile{}
+	var formParams url.Values
+	if uploads != nil && isMultipartForm(r) {
+		form, uploaded, err := extractMultipartUploads(r, uploads)
+		if err != nil {
+			return nil, err
+		}
+
+		formParams, files = form, uploaded
+	} else {
+		if err := r.ParseMultipartForm(1024); err != nil {
+			if err := r.ParseForm(); err != nil {
+				log.Debugf("[extractRequest] extract form data error: %v", err)
+			}
 		}
+
+		formParams = r.PostForm
 	}
 
-	formParams := r.PostForm
 	var data interface{}
 	if d := formParams.Get("data"); len(d) > 0 {
 		data = d
 	}
 
 	var body string
-	b, err := ioutil.ReadAll(r.Body)
-	if err != nil && err != io.EOF {
-		log.Errorf("Can't read request http body. Error: %v", err)
-	} else {
-		if rtype := header["Content-Type"]; strings.HasPrefix(rtype, "application/json") || strings.HasPrefix(rtype, "text/plain") {
-			body = string(b)
+	rtype := header["Content-Type"]
+	switch {
+	case isTextBody(rtype):
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil && err != io.EOF {
+			log.Errorf("Can't read request http body. Error: %v", err)
+		}
+
+		body = string(b)
+	case uploads != nil && uploads.StreamBody && r.ContentLength != 0 && len(files) == 0 && len(formParams) == 0:
+		maxFileSize, _, _ := uploads.limits()
+		f, err := uploadToFileStore(uploads.store(), uploadBodyField, uploadBodyField, rtype, r.Body, maxFileSize)
+		if err != nil {
+			if err == errUploadLimit {
+				return nil, uploadTooLarge(fmt.Sprintf("request body is too large, max size is %d bytes", maxFileSize))
+			}
+
+			return nil, err
+		}
+
+		if f.Size > 0 {
+			files = append(files, *f)
 		} else {
-			body = base64.StdEncoding.EncodeToString(b)
+			deleteFromFileStore(f.Store, f.ID)
+		}
+	default:
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil && err != io.EOF {
+			log.Errorf("Can't read request http body. Error: %v", err)
 		}
+
+		body = base64.StdEncoding.EncodeToString(b)
 	}
 
-	return map[string]interface{}{
+	request := map[string]interface{}{
 		"params":  params,
 		"query":   r.URL.Query(),
 		"form":    formParams,
@@ -325,6 +393,11 @@ func extractRequest(r *http.Request) map[string]interface{} {
 		"body":    body,
 		"data":    data,
 	}
+	if uploads != nil {
+		request["files"] = files
+	}
+
+	return request, nil
 }
 
 func serverRedirect(w http.ResponseWriter, uri string) {
@@ -393,8 +466,11 @@ func defaultResponse(w http.ResponseWriter, r *http.Request, res *result) {
 	case "file":
 		responseFile(w, r, res)
 		return
+	case resultTypeStream:
+		streamResponse(w, r, res, code)
+		return
 	default:
-		jsonResponseWithStatus(w, http.StatusSeeOther, "unknown encoding type")
+		errorResponse(w, r, http.StatusInternalServerError, errUnknownEncoding)
 		return
 	}
 }
@@ -425,7 +501,7 @@ func responseFile(w http.ResponseWriter, r *http.Request, res *result) {
 	content, err = base64.StdEncoding.DecodeString(res.Data)
 
 	if err != nil {
-		jsonResponseWithStatus(w, http.StatusInternalServerError, fmt.Sprintf("can't read file, error: %v", err))
+		errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("can't read file, error: %v", err))
 		return
 	}
 
@@ -465,14 +541,14 @@ func getFileHandler(storeName string) func(http.ResponseWriter, *http.Request) {
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[file get]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[file get]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
@@ -487,9 +563,9 @@ func getFileHandler(storeName string) func(http.ResponseWriter, *http.Request) {
 			t.Arguments["tokenInfo"] = cred.claims.toMap()
 		}
 
-		_, err := taskq.PushAndGetResult(&t, 0)
+		_, err := pushTask(r.Context(), &t)
 		if err != nil {
-			errorResponse(w, http.StatusBadRequest, err)
+			errorResponse(w, r, http.StatusBadRequest, err)
 			return
 		}
 
@@ -502,14 +578,14 @@ func postFileHandler(storeName string) func(http.ResponseWriter, *http.Request)
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[file post]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[file post]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
@@ -520,7 +596,7 @@ func postFileHandler(storeName string) func(http.ResponseWriter, *http.Request)
 
 		_, fileHeader, err := r.FormFile("file")
 		if err != nil {
-			jsonResponseWithStatus(w, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+			errorResponse(w, r, http.StatusBadRequest, nil)
 			return
 		}
 
@@ -535,22 +611,22 @@ func postFileHandler(storeName string) func(http.ResponseWriter, *http.Request)
 			t.Arguments["tokenInfo"] = cred.claims.toMap()
 		}
 
-		_, err = taskq.PushAndGetResult(&t, 0)
+		_, err = pushTask(r.Context(), &t)
 		if err != nil {
-			errorResponse(w, http.StatusBadRequest, err)
+			errorResponse(w, r, http.StatusBadRequest, err)
 			return
 		}
 
 		file, err := fileHeader.Open()
 		if err != nil {
-			errorResponse(w, http.StatusInternalServerError, err)
+			errorResponse(w, r, http.StatusInternalServerError, err)
 			return
 		}
 		defer file.Close()
 
 		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s/%s", sr.FSAddress(), storeName, fileID), file)
 		if err != nil {
-			errorResponse(w, http.StatusInternalServerError, err)
+			errorResponse(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
@@ -559,7 +635,7 @@ func postFileHandler(storeName string) func(http.ResponseWriter, *http.Request)
 		client := &http.Client{}
 		_, err = client.Do(req)
 		if err != nil {
-			errorResponse(w, http.StatusInternalServerError, err)
+			errorResponse(w, r, http.StatusInternalServerError, err)
 			return
 		}
 
@@ -572,14 +648,14 @@ func deleteFileHandler(storeName string) func(http.ResponseWriter, *http.Request
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[file delete]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[file delete]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
@@ -594,9 +670,9 @@ func deleteFileHandler(storeName string) func(http.ResponseWriter, *http.Request
 			t.Arguments["tokenInfo"] = cred.claims.toMap()
 		}
 
-		_, err := taskq.PushAndGetResult(&t, 0)
+		_, err := pushTask(r.Context(), &t)
 		if err != nil {
-			errorResponse(w, http.StatusBadRequest, err)
+			errorResponse(w, r, http.StatusBadRequest, err)
 			return
 		}
 
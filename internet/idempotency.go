@@ -0,0 +1,288 @@
+package internet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+	idempotencyBucket        = "idempotency-keys"
+	idempotencyMaxKeyLength  = 255
+	idempotencyMaxBodySize   = 10 << 20
+	idempotencyCleanupPeriod = time.Hour
+)
+
+var (
+	idempotencyTTL = 24 * time.Hour
+
+	// requests with idempotency key which are processing now, value is the request fingerprint
+	idempotencyInFlight       = map[string]string{}
+	idempotencyInFlightLocker sync.Mutex
+)
+
+// idempotencyRecord is the stored first response for the idempotency key.
+type idempotencyRecord struct {
+	Fingerprint string              `json:"fingerprint"`
+	Status      int                 `json:"status"`
+	Header      []idempotencyHeader `json:"header"`
+	Body        []byte              `json:"body"`
+	ExpiresAt   time.Time           `json:"expiresAt"`
+}
+
+type idempotencyHeader struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// idempotencyRecorder writes response through and keeps its copy for storing.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = cloneHeader(rec.ResponseWriter.Header())
+	}
+
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > idempotencyMaxBodySize {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *idempotencyRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// idempotencyMiddleware stores the first response for the request with Idempotency-Key header
+// and replays it for the retries with the same payload. Retry with the different payload gets 422,
+// retry while the first request is still processing gets 409. Server errors are not stored,
+// so the request can be retried with the same key.
+func idempotencyMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(headerIdempotencyKey)
+		if len(key) == 0 || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > idempotencyMaxKeyLength {
+			errorResponse(w, r, 0, newAPIError(http.StatusBadRequest, "invalid_idempotency_key", fmt.Sprintf("%s must be at most %d characters long", headerIdempotencyKey, idempotencyMaxKeyLength)))
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, idempotencyMaxBodySize+1))
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if len(body) > idempotencyMaxBodySize {
+			errorResponse(w, r, 0, newAPIError(http.StatusRequestEntityTooLarge, "request_too_large", "request body is too large for idempotent request"))
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		var userID interface{}
+		if cred, ok := r.Context().Value(credKey).(credentials); ok {
+			userID = cred.userID
+		}
+
+		storageKey := idempotencyStorageKey(userID, r, key)
+		fingerprint := idempotencyFingerprint(r, body)
+
+		idempotencyInFlightLocker.Lock()
+		if fp, ok := idempotencyInFlight[storageKey]; ok {
+			idempotencyInFlightLocker.Unlock()
+			if fp != fingerprint {
+				errorResponse(w, r, 0, errIdempotencyMismatch())
+				return
+			}
+
+			errorResponse(w, r, 0, newAPIError(http.StatusConflict, "idempotency_in_progress", "request with the same idempotency key is in progress"))
+			return
+		}
+
+		if rec, ok := loadIdempotencyRecord(storageKey); ok {
+			idempotencyInFlightLocker.Unlock()
+			if rec.Fingerprint != fingerprint {
+				errorResponse(w, r, 0, errIdempotencyMismatch())
+				return
+			}
+
+			replayIdempotencyRecord(w, rec)
+			return
+		}
+
+		idempotencyInFlight[storageKey] = fingerprint
+		idempotencyInFlightLocker.Unlock()
+
+		defer func() {
+			idempotencyInFlightLocker.Lock()
+			delete(idempotencyInFlight, storageKey)
+			idempotencyInFlightLocker.Unlock()
+		}()
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		if rec.overflow {
+			log.Debugf("[idempotency] response for %s %s is too large to store", r.Method, r.URL.Path)
+			return
+		}
+
+		saveIdempotencyRecord(storageKey, idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      rec.status,
+			Header:      recordHeader(rec.header),
+			Body:        rec.body.Bytes(),
+			ExpiresAt:   time.Now().Add(idempotencyTTL),
+		})
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+func idempotencyHandler(h http.HandlerFunc) http.HandlerFunc {
+	return idempotencyMiddleware(h).ServeHTTP
+}
+
+func errIdempotencyMismatch() error {
+	return newAPIError(http.StatusUnprocessableEntity, "idempotency_key_reused", "idempotency key was already used with a different request payload")
+}
+
+// idempotencyStorageKey scopes the key by user and route, so different clients can't collide.
+func idempotencyStorageKey(userID interface{}, r *http.Request, key string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%v\n%s\n%s\n%s", userID, r.Method, r.URL.Path, key)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func idempotencyFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", r.URL.RawQuery, r.Header.Get(headerContentType))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func loadIdempotencyRecord(storageKey string) (*idempotencyRecord, bool) {
+	data, err := localDB.Get(idempotencyBucket, storageKey)
+	if err != nil {
+		return nil, false
+	}
+
+	var rec idempotencyRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		log.Errorf("[idempotency] can't unmarshal record, error: %v", err)
+		return nil, false
+	}
+
+	if time.Now().After(rec.ExpiresAt) {
+		localDB.Delete(idempotencyBucket, storageKey)
+		return nil, false
+	}
+
+	return &rec, true
+}
+
+func saveIdempotencyRecord(storageKey string, rec idempotencyRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Errorf("[idempotency] can't marshal record, error: %v", err)
+		return
+	}
+
+	if err := localDB.Save(idempotencyBucket, storageKey, data); err != nil {
+		log.Errorf("[idempotency] can't save record, error: %v", err)
+	}
+}
+
+func replayIdempotencyRecord(w http.ResponseWriter, rec *idempotencyRecord) {
+	for _, h := range rec.Header {
+		w.Header()[h.Name] = h.Values
+	}
+
+	w.Header().Set(headerIdempotentReplayed, "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+func cloneHeader(h http.Header) http.Header {
+	res := make(http.Header, len(h))
+	for k, v := range h {
+		res[k] = append([]string(nil), v...)
+	}
+
+	return res
+}
+
+func recordHeader(h http.Header) []idempotencyHeader {
+	res := make([]idempotencyHeader, 0, len(h))
+	for k, v := range h {
+		switch k {
+		case "Content-Length", "Date", headerRequestID:
+			continue
+		}
+
+		res = append(res, idempotencyHeader{k, v})
+	}
+
+	return res
+}
+
+func idempotencyCleaner() {
+	for range time.Tick(idempotencyCleanupPeriod) {
+		keys, err := localDB.GetAllKeys(idempotencyBucket)
+		if err != nil {
+			continue
+		}
+
+		for _, key := range keys {
+			// loading removes the expired record
+			loadIdempotencyRecord(key)
+		}
+	}
+}
+
+func init() {
+	if s := os.Getenv("BLANK_IDEMPOTENCY_TTL"); len(s) > 0 {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			idempotencyTTL = d
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package internet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	useTempLocalDB(t)
+
+	var calls int
+	release := make(chan struct{})
+	h := idempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Test", "1")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%d:%s", calls, body)
+	}))
+
+	key := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(headerIdempotencyKey, key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/items", "a")
+	if w.Code != http.StatusCreated || w.Body.String() != "1:a" {
+		t.Fatalf("unexpected first response %d %q", w.Code, w.Body.String())
+	}
+
+	w = do("/items", "a")
+	if w.Code != http.StatusCreated || w.Body.String() != "1:a" || w.Header().Get("X-Test") != "1" || w.Header().Get(headerIdempotentReplayed) != "true" {
+		t.Fatalf("response was not replayed: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	if w = do("/items", "b"); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for different payload, got %d", w.Code)
+	}
+
+	if calls != 1 {
+		t.Fatalf("handler was called %d times", calls)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		do("/slow", "a")
+		close(done)
+	}()
+
+	for {
+		idempotencyInFlightLocker.Lock()
+		n := len(idempotencyInFlight)
+		idempotencyInFlightLocker.Unlock()
+		if n > 0 {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if w = do("/slow", "a"); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for request in progress, got %d", w.Code)
+	}
+
+	close(release)
+	<-done
+}
@@ -0,0 +1,844 @@
+package internet
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+const (
+	importModeUpsert = "upsert"
+	importModeInsert = "insert"
+
+	importBatchSize = 100
+	importJobType   = "import"
+
+	// xlsxMaxColumns is the max number of columns of the XLSX worksheet, the last column is XFD
+	xlsxMaxColumns = 16384
+)
+
+var (
+	importMaxSize int64 = 100 << 20
+
+	errImportUnknownFormat = errors.New("unknown import format, supported formats: csv, ndjson, xlsx")
+	errImportNoSheet       = errors.New("xlsx file has no worksheets")
+	excelEpoch             = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// importReader reads rows of the imported file. Every row is a map of column names to values.
+// Read returns io.EOF when there are no more rows.
+type importReader interface {
+	Read() (map[string]interface{}, error)
+}
+
+// importOptions describes how uploaded rows are converted to store items.
+type importOptions struct {
+	format  string
+	mode    string
+	dryRun  bool
+	mapping map[string]string
+	lang    string
+}
+
+func restImportHandler(store config.Store) http.HandlerFunc {
+	storeName := store.Store
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest import]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest import]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, importMaxSize)
+		fileName, opts, err := saveImportUpload(r)
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var tokenInfo map[string]interface{}
+		if cred.claims != nil {
+			tokenInfo = cred.claims.toMap()
+		}
+
+		j := newJob(importJobType, storeName, cred)
+		go func() {
+			defer os.Remove(fileName)
+			defer func() {
+				if rvr := recover(); rvr != nil {
+					log.Errorf("[rest import] import job %s for store %s panicked: %v", j.id, storeName, rvr)
+					j.finish(nil, fmt.Errorf("import failed: %v", rvr))
+				}
+			}()
+
+			stats, err := runImport(j, store, cred.userID, tokenInfo, fileName, opts)
+			j.finish(stats, err)
+			if err != nil {
+				log.Errorf("[rest import] import job %s for store %s failed, error: %v", j.id, storeName, err)
+			}
+		}()
+
+		w.Header().Set("Location", jobsURI+"/"+j.id)
+		apiResponse(w, r, http.StatusAccepted, j.info())
+	}
+}
+
+// saveImportUpload saves uploaded file into the temp file and returns its name with import options.
+// File can be uploaded as multipart "file" field or as the raw request body.
+func saveImportUpload(r *http.Request) (string, importOptions, error) {
+	opts := importOptions{mode: importModeUpsert}
+	var src io.Reader = r.Body
+	var uploadName string
+	contentType := r.Header.Get(headerContentType)
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return "", opts, err
+		}
+
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			return "", opts, err
+		}
+		defer file.Close()
+
+		src = file
+		uploadName = fileHeader.Filename
+		contentType = fileHeader.Header.Get(headerContentType)
+	}
+
+	opts.format = r.FormValue("format")
+	if len(opts.format) == 0 {
+		opts.format = detectImportFormat(uploadName, contentType)
+	}
+
+	switch opts.format {
+	case exportFormatCSV, exportFormatNDJSON, exportFormatXLSX:
+	default:
+		return "", opts, errImportUnknownFormat
+	}
+
+	if mode := r.FormValue("mode"); len(mode) > 0 {
+		if mode != importModeUpsert && mode != importModeInsert {
+			return "", opts, fmt.Errorf("unknown import mode %q, supported modes: upsert, insert", mode)
+		}
+
+		opts.mode = mode
+	}
+
+	opts.lang = requestLang(r)
+	opts.dryRun, _ = strconv.ParseBool(r.FormValue("dryRun"))
+	if m := r.FormValue("mapping"); len(m) > 0 {
+		if err := json.Unmarshal([]byte(m), &opts.mapping); err != nil {
+			return "", opts, fmt.Errorf("invalid mapping: %v", err)
+		}
+	}
+
+	f, err := ioutil.TempFile("", "blank-import-")
+	if err != nil {
+		return "", opts, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, src); err != nil {
+		os.Remove(f.Name())
+		return "", opts, err
+	}
+
+	return f.Name(), opts, nil
+}
+
+func detectImportFormat(fileName, contentType string) string {
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), ".")) {
+	case exportFormatCSV:
+		return exportFormatCSV
+	case exportFormatNDJSON, "jsonl":
+		return exportFormatNDJSON
+	case exportFormatXLSX:
+		return exportFormatXLSX
+	}
+
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return exportFormatCSV
+	case strings.HasPrefix(contentType, applicationNDJSON):
+		return exportFormatNDJSON
+	case strings.HasPrefix(contentType, applicationXLSX):
+		return exportFormatXLSX
+	}
+
+	return ""
+}
+
+func openImportReader(fileName, format string) (importReader, io.Closer, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ir importReader
+	switch format {
+	case exportFormatCSV:
+		ir, err = newCSVImportReader(f)
+	case exportFormatNDJSON:
+		ir = newNDJSONImportReader(f)
+	case exportFormatXLSX:
+		var info os.FileInfo
+		if info, err = f.Stat(); err == nil {
+			ir, err = newXLSXImportReader(f, info.Size())
+		}
+	default:
+		err = errImportUnknownFormat
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return ir, f, nil
+}
+
+// runImport validates and writes all rows of the file. First pass counts rows to report job progress.
+func runImport(j *job, store config.Store, userID interface{}, tokenInfo map[string]interface{}, fileName string, opts importOptions) (map[string]int, error) {
+	total, err := countImportRows(fileName, opts.format)
+	if err != nil {
+		return nil, err
+	}
+
+	j.incStat("total", total)
+	ir, closer, err := openImportReader(fileName, opts.format)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	op := bulkOpCreate
+	if opts.mode == importModeInsert {
+		op = bulkOpInsert
+	}
+
+	// data rows in csv and xlsx files start after the header row
+	rowOffset := 2
+	if opts.format == exportFormatNDJSON {
+		rowOffset = 1
+	}
+
+	labels, err := importLabels(store, opts.lang)
+	if err != nil {
+		return nil, err
+	}
+
+	var processed int
+	var batch []bulkOperation
+	var batchRows []int
+	flush := func() {
+		if len(batch) > 0 && !opts.dryRun {
+			results := make(chan bulkResult, len(batch))
+			processBulkOperations(context.Background(), store.Store, userID, tokenInfo, batch, results)
+			close(results)
+			for res := range results {
+				if len(res.Error) > 0 {
+					j.incStat("failed", 1)
+					j.addRowError(batchRows[res.Index], errors.New(res.Error))
+					continue
+				}
+
+				j.incStat("written", 1)
+			}
+		}
+
+		processed += len(batch)
+		batch, batchRows = batch[:0], batchRows[:0]
+		if total > 0 {
+			j.setProgress(float64(processed) / float64(total))
+		}
+	}
+
+	for i := 0; ; i++ {
+		row, err := ir.Read()
+		if err == io.EOF {
+			break
+		}
+
+		rowNumber := i + rowOffset
+		if err != nil {
+			return j.info().Stats, fmt.Errorf("row %d: %v", rowNumber, err)
+		}
+
+		// rows are validated in dry run too, the same validation runs again before writing
+		item, err := importItem(store, row, opts.mapping, labels)
+		if err == nil {
+			err = validateImportItem(store.Store, item)
+		}
+
+		if err != nil {
+			j.incStat("invalid", 1)
+			j.addRowError(rowNumber, err)
+			processed++
+			continue
+		}
+
+		j.incStat("valid", 1)
+		batch = append(batch, bulkOperation{Op: op, Item: item})
+		batchRows = append(batchRows, rowNumber)
+		if len(batch) >= importBatchSize {
+			flush()
+		}
+	}
+
+	flush()
+
+	return j.info().Stats, nil
+}
+
+func countImportRows(fileName, format string) (int, error) {
+	ir, closer, err := openImportReader(fileName, format)
+	if err != nil {
+		return 0, err
+	}
+	defer closer.Close()
+
+	var n int
+	for {
+		if _, err := ir.Read(); err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+
+			return n, err
+		}
+
+		n++
+	}
+}
+
+// validateImportItem validates item as the bulk create operation does. Error has messages of all invalid fields.
+func validateImportItem(storeName string, item map[string]interface{}) error {
+	err := validateStoreItem(storeName, item, false)
+	e, ok := err.(*apiError)
+	if !ok || len(e.Fields) == 0 {
+		return err
+	}
+
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// importLabels returns map of translated column labels to prop names, the same labels are used in export.
+func importLabels(store config.Store, lang string) (map[string]string, error) {
+	columns, err := exportColumns(store, "", lang)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]string, len(columns))
+	for _, col := range columns {
+		labels[col.label] = col.prop
+	}
+
+	return labels, nil
+}
+
+// importItem converts row to the store item using column to prop mapping.
+// If mapping is not provided, columns are matched with prop names or with column labels of the export.
+func importItem(store config.Store, row map[string]interface{}, mapping, labels map[string]string) (map[string]interface{}, error) {
+	item := map[string]interface{}{}
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		propName := column
+		if mapping != nil {
+			var ok bool
+			if propName, ok = mapping[column]; !ok {
+				continue
+			}
+		} else if _, err := findProp(store, propName); err != nil && len(labels[column]) > 0 {
+			propName = labels[column]
+		}
+
+		if len(propName) == 0 {
+			continue
+		}
+
+		p, err := findProp(store, propName)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %v", column, err)
+		}
+
+		v, err := importValue(store, p, propName, row[column])
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %v", column, err)
+		}
+
+		if v != nil {
+			setProp(item, propName, v)
+		}
+	}
+
+	for name, p := range store.Props {
+		if required, _ := p.Required.(bool); required && item[name] == nil && p.Default == nil && name != "_id" {
+			return nil, fmt.Errorf("prop %q is required", name)
+		}
+	}
+
+	return item, nil
+}
+
+// setProp sets value of the prop in item. Nested props are separated by dots.
+func setProp(item map[string]interface{}, propName string, value interface{}) {
+	path := strings.Split(propName, ".")
+	for _, p := range path[:len(path)-1] {
+		nested, ok := item[p].(map[string]interface{})
+		if !ok {
+			nested = map[string]interface{}{}
+			item[p] = nested
+		}
+
+		item = nested
+	}
+
+	item[path[len(path)-1]] = value
+}
+
+// importValue converts value from the imported file to the type of the prop.
+// Empty values are returned as nil.
+func importValue(store config.Store, p config.Prop, propName string, v interface{}) (interface{}, error) {
+	s, isString := v.(string)
+	if isString {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			return nil, nil
+		}
+	}
+
+	if v == nil {
+		return nil, nil
+	}
+
+	switch p.Type {
+	case config.PropInt, config.PropFloat, config.PropBool:
+		if isString {
+			return coerceFilterValue(p, propName, s)
+		}
+
+		switch val := v.(type) {
+		case float64:
+			if p.Type == config.PropBool {
+				return val != 0, nil
+			}
+
+			if p.Type == config.PropInt {
+				if val != math.Trunc(val) {
+					return nil, fmt.Errorf("invalid value %v for field %q: int expected", val, propName)
+				}
+
+				return int(val), nil
+			}
+
+			return val, nil
+		case bool:
+			if p.Type == config.PropBool {
+				return val, nil
+			}
+		}
+
+		return nil, fmt.Errorf("invalid value %v for field %q: %s expected", v, propName, p.Type)
+	case config.PropDate, config.PropDateOnly:
+		if isString {
+			return coerceFilterValue(p, propName, s)
+		}
+
+		if days, ok := v.(float64); ok {
+			return excelEpoch.Add(time.Duration(days * float64(24*time.Hour))).Round(time.Second), nil
+		}
+
+		return nil, fmt.Errorf("invalid value %v for field %q: date expected", v, propName)
+	case config.PropRef:
+		if f, ok := v.(float64); ok {
+			s = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+
+		return coerceFilterValue(p, propName, s)
+	case config.PropRefList:
+		if list, ok := v.([]interface{}); ok {
+			return list, nil
+		}
+
+		var res []interface{}
+		for _, id := range strings.Split(s, ",") {
+			ref, err := coerceFilterValue(p, propName, strings.TrimSpace(id))
+			if err != nil {
+				return nil, err
+			}
+
+			res = append(res, ref)
+		}
+
+		return res, nil
+	case config.PropObject, config.PropObjectList, config.PropAny:
+		if isString {
+			var res interface{}
+			if err := json.Unmarshal([]byte(s), &res); err != nil {
+				return nil, fmt.Errorf("invalid value for field %q: %v", propName, err)
+			}
+
+			return res, nil
+		}
+
+		return v, nil
+	case config.PropVirtual, config.PropVirtualClient, config.PropVirtualRefList, config.PropAction, config.PropWidget, config.PropComments:
+		return nil, fmt.Errorf("field %q of type %q can't be imported", propName, p.Type)
+	}
+
+	if isString {
+		return s, nil
+	}
+
+	return exportCellValue(v), nil
+}
+
+type csvImportReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCSVImportReader(r io.Reader) (importReader, error) {
+	cr := csv.NewReader(bufio.NewReader(r))
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return &csvImportReader{r: cr}, nil
+		}
+
+		return nil, err
+	}
+
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	return &csvImportReader{r: cr, header: header}, nil
+}
+
+func (c *csvImportReader) Read() (map[string]interface{}, error) {
+	if c.header == nil {
+		return nil, io.EOF
+	}
+
+	record, err := c.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]interface{}, len(c.header))
+	for i, column := range c.header {
+		if i < len(record) {
+			row[column] = record[i]
+		}
+	}
+
+	return row, nil
+}
+
+type ndjsonImportReader struct {
+	s *bufio.Scanner
+}
+
+func newNDJSONImportReader(r io.Reader) importReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16<<20)
+	return &ndjsonImportReader{s}
+}
+
+func (n *ndjsonImportReader) Read() (map[string]interface{}, error) {
+	for n.s.Scan() {
+		line := strings.TrimSpace(n.s.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		var row map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			return nil, err
+		}
+
+		return row, nil
+	}
+
+	if err := n.s.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+// xlsxImportReader reads the first worksheet of the xlsx file. First row is used as header.
+type xlsxImportReader struct {
+	d             *xml.Decoder
+	sheet         io.Closer
+	sharedStrings []string
+	header        []string
+}
+
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"is"`
+}
+
+func newXLSXImportReader(r io.ReaderAt, size int64) (importReader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheetFile *zip.File
+	var sheetFiles []*zip.File
+	x := &xlsxImportReader{}
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "xl/sharedStrings.xml":
+			if x.sharedStrings, err = readXLSXSharedStrings(f); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(f.Name, "xl/worksheets/sheet") && strings.HasSuffix(f.Name, ".xml"):
+			sheetFiles = append(sheetFiles, f)
+		}
+	}
+
+	for _, f := range sheetFiles {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			sheetFile = f
+		}
+	}
+
+	if sheetFile == nil {
+		if len(sheetFiles) == 0 {
+			return nil, errImportNoSheet
+		}
+
+		sheetFile = sheetFiles[0]
+	}
+
+	rc, err := sheetFile.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	x.d = xml.NewDecoder(rc)
+	x.sheet = rc
+	header, err := x.readRow()
+	if err != nil && err != io.EOF {
+		rc.Close()
+		return nil, err
+	}
+
+	for _, v := range header {
+		x.header = append(x.header, exportCellValue(v))
+	}
+
+	return x, nil
+}
+
+func readXLSXSharedStrings(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var sst struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&sst); err != nil {
+		return nil, err
+	}
+
+	res := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		res[i] = item.Text
+		for _, run := range item.Runs {
+			res[i] += run.Text
+		}
+	}
+
+	return res, nil
+}
+
+func (x *xlsxImportReader) Read() (map[string]interface{}, error) {
+	if x.header == nil {
+		return nil, io.EOF
+	}
+
+	values, err := x.readRow()
+	if err != nil {
+		if err == io.EOF {
+			x.sheet.Close()
+		}
+
+		return nil, err
+	}
+
+	row := make(map[string]interface{}, len(x.header))
+	for i, column := range x.header {
+		if i < len(values) {
+			row[column] = values[i]
+		}
+	}
+
+	return row, nil
+}
+
+func (x *xlsxImportReader) readRow() ([]interface{}, error) {
+	var values []interface{}
+	inRow := false
+	for {
+		token, err := x.d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				inRow = true
+			case "c":
+				var cell xlsxCell
+				if err := x.d.DecodeElement(&cell, &t); err != nil {
+					return nil, err
+				}
+
+				index := len(values)
+				if len(cell.Ref) > 0 {
+					if index, err = xlsxColumnIndex(cell.Ref); err != nil {
+						return nil, err
+					}
+				} else if index >= xlsxMaxColumns {
+					return nil, fmt.Errorf("row has more than %d cells", xlsxMaxColumns)
+				}
+
+				for len(values) <= index {
+					values = append(values, nil)
+				}
+
+				values[index] = x.cellValue(cell)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "row" && inRow {
+				return values, nil
+			}
+		}
+	}
+}
+
+func (x *xlsxImportReader) cellValue(cell xlsxCell) interface{} {
+	switch cell.Type {
+	case "s":
+		i, err := strconv.Atoi(cell.Value)
+		if err != nil || i < 0 || i >= len(x.sharedStrings) {
+			return nil
+		}
+
+		return x.sharedStrings[i]
+	case "inlineStr":
+		text := cell.Inline.Text
+		for _, run := range cell.Inline.Runs {
+			text += run.Text
+		}
+
+		return text
+	case "b":
+		return cell.Value == "1"
+	case "str", "e":
+		return cell.Value
+	}
+
+	if len(cell.Value) == 0 {
+		return nil
+	}
+
+	if f, err := strconv.ParseFloat(cell.Value, 64); err == nil {
+		return f
+	}
+
+	return cell.Value
+}
+
+// xlsxColumnIndex returns zero based column index from the cell reference like "AB12".
+func xlsxColumnIndex(ref string) (int, error) {
+	var index int
+	for _, c := range ref {
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+
+		if c < 'A' || c > 'Z' {
+			break
+		}
+
+		index = index*26 + int(c-'A'+1)
+		if index > xlsxMaxColumns {
+			return 0, fmt.Errorf("invalid cell reference %q, max column is XFD", ref)
+		}
+	}
+
+	if index == 0 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+
+	return index - 1, nil
+}
+
+func init() {
+	// BLANK_IMPORT_MAX_SIZE is the max size of the imported file in megabytes
+	if s := os.Getenv("BLANK_IMPORT_MAX_SIZE"); len(s) > 0 {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+			importMaxSize = n << 20
+		}
+	}
+}
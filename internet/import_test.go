@@ -0,0 +1,188 @@
+package internet
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+func TestXLSXImportRoundTrip(t *testing.T) {
+	columns := []exportColumn{{"name", "Name"}, {"age", "age"}, {"active", "active"}}
+	items := []map[string]interface{}{
+		{"name": "John", "age": 42, "active": true},
+		{"name": "Jane <&>", "age": 7},
+	}
+
+	var buf bytes.Buffer
+	rw, err := newXLSXRowWriter(&buf, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rw.WriteHeader(columns); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range items {
+		if err := rw.WriteRow(columns, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ir, err := newXLSXImportReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]interface{}{
+		{"name": "John", "age": 42, "active": true},
+		{"name": "Jane <&>", "age": 7},
+	}
+	for i, exp := range expected {
+		row, err := ir.Read()
+		if err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+
+		item, err := importItem(filtersTestStore, row, nil, map[string]string{"Name": "name"})
+		if err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+
+		if !reflect.DeepEqual(item, exp) {
+			t.Fatalf("row %d: expected %v, got %v", i, exp, item)
+		}
+	}
+
+	if _, err := ir.Read(); err != io.EOF {
+		t.Fatalf("io.EOF expected, got %v", err)
+	}
+}
+
+func TestCSVImport(t *testing.T) {
+	ir, err := newCSVImportReader(strings.NewReader("Name,Age,City\nJohn,42,Moscow\nJane,old,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := map[string]string{"Name": "name", "Age": "age", "City": "address.city"}
+	row, err := ir.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := importItem(filtersTestStore, row, mapping, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"name": "John", "age": 42, "address": map[string]interface{}{"city": "Moscow"}}
+	if !reflect.DeepEqual(item, expected) {
+		t.Fatalf("expected %v, got %v", expected, item)
+	}
+
+	if row, err = ir.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := importItem(filtersTestStore, row, mapping, nil); err == nil {
+		t.Fatal("error expected for invalid int value")
+	}
+}
+
+func TestXLSXColumnIndex(t *testing.T) {
+	testData := []struct {
+		ref      string
+		expected int
+		err      bool
+	}{
+		{"A1", 0, false},
+		{"AB12", 27, false},
+		{"ab12", 27, false},
+		{"XFD1", xlsxMaxColumns - 1, false},
+		{"XFE1", 0, true},
+		{"XFDXFDXFD1", 0, true},
+		{"1", 0, true},
+		{"", 0, true},
+	}
+
+	for _, v := range testData {
+		index, err := xlsxColumnIndex(v.ref)
+		if (err != nil) != v.err || index != v.expected {
+			t.Errorf("xlsxColumnIndex(%q): expected %d (error %v), got %d, %v", v.ref, v.expected, v.err, index, err)
+		}
+	}
+}
+
+func TestRunImportValidatesRows(t *testing.T) {
+	store := config.Store{
+		Store: "importTestUsers",
+		Props: map[string]config.Prop{
+			"name": {Type: config.PropString, MinLength: 3},
+			"code": {Type: config.PropString, Pattern: "^[A-Z]+$"},
+		},
+	}
+
+	settingsLocker.Lock()
+	stores[store.Store] = store
+	settingsLocker.Unlock()
+	defer func() {
+		settingsLocker.Lock()
+		delete(stores, store.Store)
+		settingsLocker.Unlock()
+	}()
+
+	fileName := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(fileName, []byte("name,code\nJohn,AB\nJo,AB\nJane,ab\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := importOptions{format: exportFormatCSV, mode: importModeUpsert, dryRun: true}
+	j := newJob("import", store.Store, credentials{userID: "u1"})
+	stats, err := runImport(j, store, "u1", nil, fileName, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{"total": 3, "valid": 1, "invalid": 2}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Fatalf("dry run stats %v, expected: %v", stats, expected)
+	}
+
+	if len(j.rowErrors) != 2 || j.rowErrors[0].Row != 3 || j.rowErrors[1].Row != 4 {
+		t.Fatalf("unexpected row errors %v", j.rowErrors)
+	}
+
+	if msg := j.rowErrors[0].Error; msg != "name must be at least 3 characters long" {
+		t.Fatalf("unexpected row error %q", msg)
+	}
+
+	// only the valid row is written
+	served := serveTasks(1, func(task *taskq.Task) (interface{}, string) {
+		return task.Arguments["item"], ""
+	})
+
+	opts.dryRun = false
+	j = newJob("import", store.Store, credentials{userID: "u1"})
+	if stats, err = runImport(j, store, "u1", nil, fileName, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = map[string]int{"total": 3, "valid": 1, "invalid": 2, "written": 1}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Fatalf("import stats %v, expected: %v", stats, expected)
+	}
+
+	<-served
+}
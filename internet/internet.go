@@ -1,6 +1,7 @@
 package internet
 
 import (
+	"context"
 	"mime"
 	"net/http"
 	"os"
@@ -67,6 +68,12 @@ func initBaseRoutes() {
 	r.Get("/common-settings", commonSettingsHandler)
 
 	wampInit()
+	openLocalDB()
+	initJobRoutes()
+	initGraphQLRoutes()
+	initWebhookRoutes()
+	go idempotencyCleaner()
+	go uploadsCleaner()
 	r.Handle("/wamp", websocket.Handler(wampHandler))
 
 	r.With(allowAnyOriginMiddleware).Post("/login", loginHandler)
@@ -167,7 +174,7 @@ func checkJWTOptionsHandler(w http.ResponseWriter, r *http.Request) {
 func checkUserHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(1024); err != nil {
 		if err := r.ParseForm(); err != nil {
-			invalidArguments(w)
+			invalidArguments(w, r)
 			return
 		}
 	}
@@ -175,7 +182,7 @@ func checkUserHandler(w http.ResponseWriter, r *http.Request) {
 	form := r.PostForm
 	email := form.Get("email")
 	if len(email) == 0 {
-		invalidArguments(w)
+		invalidArguments(w, r)
 		return
 	}
 
@@ -193,7 +200,7 @@ func checkUserHandler(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	_res, err := taskq.PushAndGetResult(&t, 0)
+	_res, err := pushTask(r.Context(), &t)
 	if err != nil {
 		jsonResponse(w, "USER_NOT_FOUND")
 		return
@@ -201,19 +208,19 @@ func checkUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	res, ok := _res.(map[string]interface{})
 	if !ok {
-		errorResponse(w, http.StatusInternalServerError, berrors.ErrError)
+		errorResponse(w, r, http.StatusInternalServerError, berrors.ErrError)
 		return
 	}
 
 	_items, ok := res["items"]
 	if !ok {
-		errorResponse(w, http.StatusInternalServerError, berrors.ErrError)
+		errorResponse(w, r, http.StatusInternalServerError, berrors.ErrError)
 		return
 	}
 
 	items, ok := _items.([]interface{})
 	if !ok {
-		errorResponse(w, http.StatusInternalServerError, berrors.ErrError)
+		errorResponse(w, r, http.StatusInternalServerError, berrors.ErrError)
 		return
 	}
 
@@ -238,21 +245,19 @@ func commonSettingsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	resChan := taskq.Push(&t)
-
-	res := <-resChan
-	if res.Err != "" {
-		jsonResponseWithStatus(w, http.StatusNotFound, res.Err)
+	res, err := pushTask(r.Context(), &t)
+	if err != nil {
+		errorResponse(w, r, http.StatusNotFound, err)
 		return
 	}
 
-	jsonResponse(w, res.Result)
+	jsonResponse(w, res)
 }
 
 func loginHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(1024); err != nil {
 		if err := r.ParseForm(); err != nil {
-			invalidArguments(w)
+			invalidArguments(w, r)
 			return
 		}
 	}
@@ -262,7 +267,7 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 	password := form.Get("password")
 	hashedPassword := form.Get("hashedPassword")
 	if len(login) == 0 || (len(password) == 0 && len(hashedPassword) == 0) {
-		invalidArguments(w)
+		invalidArguments(w, r)
 		return
 	}
 
@@ -278,28 +283,28 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		Arguments: fp,
 	}
 
-	res, err := taskq.PushAndGetResult(&t, 0)
+	res, err := pushTask(r.Context(), &t)
 	if err != nil {
-		errorResponse(w, http.StatusForbidden, err)
+		errorResponse(w, r, http.StatusForbidden, err)
 		return
 	}
 
 	user, ok := res.(map[string]interface{})
 	if !ok {
 		log.Warn("Invalid type of result on http login")
-		errorResponse(w, http.StatusInternalServerError, berrors.ErrError)
+		errorResponse(w, r, http.StatusInternalServerError, berrors.ErrError)
 		return
 	}
 
 	accessToken, err := sessions.NewSession(user, sessionID)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err)
+		errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
 	claims, err := extractClaimsFromJWT(accessToken)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err)
+		errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -344,9 +349,9 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 		UserID:    userID,
 		Arguments: arguments,
 	}
-	_, err = taskq.PushAndGetResult(&t, 30*time.Second)
+	_, err = pushTask(r.Context(), &t)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err)
+		errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -359,7 +364,7 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 
 	err = sessions.DeleteSession(apiKey)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err)
+		errorResponse(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -369,8 +374,7 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 			UserID:    userID,
 			Arguments: arguments,
 		}
-		_, err = taskq.PushAndGetResult(&t, 30*time.Second)
-		if err != nil {
+		if _, err := pushTask(context.Background(), &t); err != nil {
 			log.Errorf("User %s didSignOut error: %v", userID, err)
 		}
 	}()
@@ -388,7 +392,7 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 func registerHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(1024); err != nil {
 		if err := r.ParseForm(); err != nil {
-			invalidArguments(w)
+			invalidArguments(w, r)
 			return
 		}
 	}
@@ -404,9 +408,9 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 		Arguments: args,
 	}
 
-	res, err := taskq.PushAndGetResult(&t, 0)
+	res, err := pushTask(r.Context(), &t)
 	if err != nil {
-		errorResponse(w, http.StatusBadRequest, err)
+		errorResponse(w, r, http.StatusBadRequest, err)
 		return
 	}
 
@@ -416,7 +420,7 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 func resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(1024); err != nil {
 		if err := r.ParseForm(); err != nil {
-			invalidArguments(w)
+			invalidArguments(w, r)
 			return
 		}
 	}
@@ -432,9 +436,9 @@ func resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 		Arguments: args,
 	}
 
-	res, err := taskq.PushAndGetResult(&t, 0)
+	res, err := pushTask(r.Context(), &t)
 	if err != nil {
-		errorResponse(w, http.StatusSeeOther, err)
+		errorResponse(w, r, 0, err)
 		return
 	}
 
@@ -444,7 +448,7 @@ func resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
 func sendResetLinkHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(1024); err != nil {
 		if err := r.ParseForm(); err != nil {
-			invalidArguments(w)
+			invalidArguments(w, r)
 			return
 		}
 	}
@@ -452,7 +456,7 @@ func sendResetLinkHandler(w http.ResponseWriter, r *http.Request) {
 	formParams := r.PostForm
 	email := formParams.Get("email")
 	if len(email) == 0 {
-		invalidArguments(w)
+		invalidArguments(w, r)
 		return
 	}
 
@@ -462,9 +466,9 @@ func sendResetLinkHandler(w http.ResponseWriter, r *http.Request) {
 			"email": email,
 		},
 	}
-	res, err := taskq.PushAndGetResult(&t, 0)
+	res, err := pushTask(r.Context(), &t)
 	if err != nil {
-		errorResponse(w, http.StatusSeeOther, err)
+		errorResponse(w, r, 0, err)
 		return
 	}
 
@@ -0,0 +1,370 @@
+package internet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/getblank/uuid"
+
+	"github.com/getblank/blank-one/sessions"
+)
+
+const (
+	jobStatusRunning   = "running"
+	jobStatusCompleted = "completed"
+	jobStatusFailed    = "failed"
+
+	jobsURI          = apiV1baseURI + "jobs"
+	uriJobs          = "com.jobs"
+	jobEventName     = "job"
+	jobStreamPeriod  = time.Second
+	jobTTL           = 24 * time.Hour
+	jobMaxRowErrors  = 10000
+	jobCleanupPeriod = time.Hour
+)
+
+var (
+	// jobs are not persisted, all jobs are lost on restart
+	jobs       = map[string]*job{}
+	jobsLocker sync.RWMutex
+)
+
+// job is a long-running background operation started by the user.
+type job struct {
+	id         string
+	typ        string
+	store      string
+	userID     interface{}
+	sessionID  string
+	status     string
+	progress   float64
+	stats      map[string]int
+	result     interface{}
+	err        string
+	rowErrors  []jobRowError
+	createdAt  time.Time
+	updatedAt  time.Time
+	finishedAt time.Time
+	done       chan struct{}
+	sync.RWMutex
+}
+
+type jobRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type jobInfo struct {
+	ID          string         `json:"_id"`
+	Type        string         `json:"type"`
+	Store       string         `json:"store,omitempty"`
+	Status      string         `json:"status"`
+	Progress    float64        `json:"progress"`
+	Stats       map[string]int `json:"stats,omitempty"`
+	Result      interface{}    `json:"result,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	ErrorsCount int            `json:"errorsCount"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	FinishedAt  *time.Time     `json:"finishedAt,omitempty"`
+}
+
+func newJob(typ, store string, cred credentials) *job {
+	now := time.Now()
+	j := &job{
+		id:        uuid.NewV4(),
+		typ:       typ,
+		store:     store,
+		userID:    cred.userID,
+		sessionID: cred.sessionID,
+		status:    jobStatusRunning,
+		stats:     map[string]int{},
+		createdAt: now,
+		updatedAt: now,
+		done:      make(chan struct{}),
+	}
+
+	jobsLocker.Lock()
+	jobs[j.id] = j
+	jobsLocker.Unlock()
+
+	return j
+}
+
+func getJob(id string) (*job, bool) {
+	jobsLocker.RLock()
+	defer jobsLocker.RUnlock()
+
+	j, ok := jobs[id]
+	return j, ok
+}
+
+// setProgress sets job progress in range from 0 to 1.
+func (j *job) setProgress(progress float64) {
+	j.Lock()
+	j.progress = progress
+	j.updatedAt = time.Now()
+	j.Unlock()
+}
+
+func (j *job) incStat(name string, n int) {
+	j.Lock()
+	j.stats[name] += n
+	j.updatedAt = time.Now()
+	j.Unlock()
+}
+
+func (j *job) addRowError(row int, err error) {
+	j.Lock()
+	if len(j.rowErrors) < jobMaxRowErrors {
+		j.rowErrors = append(j.rowErrors, jobRowError{row, err.Error()})
+	}
+	j.Unlock()
+}
+
+// finish sets job result and notifies the owner session about job completion.
+func (j *job) finish(result interface{}, err error) {
+	j.Lock()
+	j.updatedAt = time.Now()
+	j.finishedAt = j.updatedAt
+	j.result = result
+	if err != nil {
+		j.status = jobStatusFailed
+		j.err = toAPIError(0, err).Message
+	} else {
+		j.status = jobStatusCompleted
+		j.progress = 1
+	}
+	j.Unlock()
+
+	close(j.done)
+	publishJobEvent(j)
+}
+
+// publishJobEvent sends job info to the com.user WAMP subscriptions of the job owner session.
+func publishJobEvent(j *job) {
+	if len(j.sessionID) == 0 {
+		return
+	}
+
+	subscribers, err := sessions.Subscribers(j.sessionID, uriSubUser)
+	if err != nil || len(subscribers) == 0 {
+		return
+	}
+
+	onSREvent(uriSubUser, map[string]interface{}{"event": jobEventName, "job": j.info()}, subscribers)
+}
+
+// onJobEvent handles progress events published by workers for the job, e.g. {"progress": 0.5}.
+func onJobEvent(uri string, event interface{}) {
+	j, ok := getJob(strings.TrimPrefix(uri, uriJobs+"."))
+	if !ok {
+		return
+	}
+
+	m, ok := event.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if progress, ok := numberValue(m["progress"]); ok && progress >= 0 && progress <= 1 {
+		j.setProgress(progress)
+	}
+}
+
+func (j *job) info() jobInfo {
+	j.RLock()
+	defer j.RUnlock()
+
+	stats := make(map[string]int, len(j.stats))
+	for k, v := range j.stats {
+		stats[k] = v
+	}
+
+	info := jobInfo{
+		ID:          j.id,
+		Type:        j.typ,
+		Store:       j.store,
+		Status:      j.status,
+		Progress:    j.progress,
+		Stats:       stats,
+		Result:      j.result,
+		Error:       j.err,
+		ErrorsCount: len(j.rowErrors),
+		CreatedAt:   j.createdAt,
+		UpdatedAt:   j.updatedAt,
+	}
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		info.FinishedAt = &finishedAt
+	}
+
+	return info
+}
+
+func (j *job) ownedBy(userID interface{}) bool {
+	return fmt.Sprint(j.userID) == fmt.Sprint(userID)
+}
+
+func initJobRoutes() {
+	jr := r.With(allowAnyOriginMiddleware, jwtAuthMiddleware(false))
+	jr.Get(jobsURI+"/{id}", jobHandler)
+	jr.Get(jobsURI+"/{id}/errors", jobErrorsHandler)
+
+	go jobsCleaner()
+}
+
+func jobFromRequest(w http.ResponseWriter, r *http.Request) (*job, bool) {
+	c := r.Context().Value(credKey)
+	if c == nil {
+		log.Warn("[job]: no cred in echo context")
+		errorResponse(w, r, http.StatusUnauthorized, nil)
+		return nil, false
+	}
+
+	cred, ok := c.(credentials)
+	if !ok {
+		log.Warn("[job]: invalid cred in echo context")
+		errorResponse(w, r, http.StatusUnauthorized, nil)
+		return nil, false
+	}
+
+	j, ok := getJob(chi.URLParam(r, "id"))
+	if !ok || !j.ownedBy(cred.userID) {
+		errorResponse(w, r, http.StatusNotFound, nil)
+		return nil, false
+	}
+
+	return j, true
+}
+
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+	j, ok := jobFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), textEventStream) {
+		jobStreamHandler(w, r, j)
+		return
+	}
+
+	apiResponse(w, r, http.StatusOK, j.info())
+}
+
+// jobStreamHandler streams job info as SSE "progress" events until the job is finished.
+// The last event is "done" with the final job info.
+func jobStreamHandler(w http.ResponseWriter, r *http.Request, j *job) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	w.Header().Set(headerContentType, textEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(event string, info jobInfo) bool {
+		data, err := json.Marshal(info)
+		if err != nil {
+			log.Errorf("[job stream] can't marshal job info, error: %v", err)
+			return false
+		}
+
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			log.Debugf("[job stream] write error: %v", err)
+			return false
+		}
+
+		flusher.Flush()
+		return true
+	}
+
+	ticker := time.NewTicker(jobStreamPeriod)
+	defer ticker.Stop()
+
+	var lastUpdate time.Time
+	for {
+		select {
+		case <-j.done:
+			write("done", j.info())
+			return
+		default:
+		}
+
+		if info := j.info(); info.UpdatedAt != lastUpdate {
+			lastUpdate = info.UpdatedAt
+			if !write("progress", info) {
+				return
+			}
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-j.done:
+		case <-ticker.C:
+		}
+	}
+}
+
+// jobErrorsHandler responds with per-row job errors as CSV or JSON depending on format query param.
+func jobErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	j, ok := jobFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	j.RLock()
+	rowErrors := make([]jobRowError, len(j.rowErrors))
+	copy(rowErrors, j.rowErrors)
+	j.RUnlock()
+
+	if r.URL.Query().Get("format") == "json" {
+		jsonResponse(w, rowErrors)
+		return
+	}
+
+	w.Header().Set(headerContentType, textCSV)
+	w.Header().Set(headerContentDisposition, fmt.Sprintf("attachment; filename=%q", j.id+"-errors.csv"))
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"row", "error"}); err != nil {
+		log.Debugf("[jobErrorsHandler] write error: %v", err)
+		return
+	}
+
+	for _, e := range rowErrors {
+		if err := cw.Write([]string{strconv.Itoa(e.Row), e.Error}); err != nil {
+			log.Debugf("[jobErrorsHandler] write error: %v", err)
+			return
+		}
+	}
+
+	cw.Flush()
+}
+
+// jobsCleaner removes finished jobs after jobTTL.
+func jobsCleaner() {
+	for range time.Tick(jobCleanupPeriod) {
+		now := time.Now()
+		jobsLocker.Lock()
+		for id, j := range jobs {
+			j.RLock()
+			expired := !j.finishedAt.IsZero() && now.Sub(j.finishedAt) > jobTTL
+			j.RUnlock()
+			if expired {
+				delete(jobs, id)
+			}
+		}
+		jobsLocker.Unlock()
+	}
+}
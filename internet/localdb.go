@@ -0,0 +1,130 @@
+package internet
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	localDBPath = "./blank-one.db"
+
+	// localDB keeps idempotency records, webhook subscriptions and deliveries, temporary uploads
+	// in their own buckets. It is opened by initBaseRoutes, tests replace it with the temporary one.
+	localDB *boltStore
+
+	errLocalNotFound = errors.New("not found")
+)
+
+// boltStore is the key-value storage on the local disk.
+type boltStore struct {
+	db *bolt.DB
+}
+
+func openBoltStore(path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	return &boltStore{db}, nil
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *boltStore) Save(bucket, key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(key), value)
+	})
+}
+
+// Get returns errLocalNotFound if there is no value for the key.
+func (s *boltStore) Get(bucket, key string) ([]byte, error) {
+	var res []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return errLocalNotFound
+		}
+
+		v := b.Get([]byte(key))
+		if v == nil {
+			return errLocalNotFound
+		}
+
+		res = append([]byte(nil), v...)
+		return nil
+	})
+
+	return res, err
+}
+
+func (s *boltStore) Delete(bucket, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.Delete([]byte(key))
+	})
+}
+
+// GetAll returns all values of the bucket ordered by keys.
+func (s *boltStore) GetAll(bucket string) ([][]byte, error) {
+	var res [][]byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			res = append(res, append([]byte(nil), v...))
+			return nil
+		})
+	})
+
+	return res, err
+}
+
+func (s *boltStore) GetAllKeys(bucket string) ([]string, error) {
+	var res []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			res = append(res, string(k))
+			return nil
+		})
+	})
+
+	return res, err
+}
+
+func openLocalDB() {
+	db, err := openBoltStore(localDBPath)
+	if err != nil {
+		log.Fatalf("[local db] can't open %s, error: %v", localDBPath, err)
+	}
+
+	localDB = db
+}
+
+func init() {
+	if s := os.Getenv("BLANK_DB_PATH"); len(s) > 0 {
+		localDBPath = s
+	}
+}
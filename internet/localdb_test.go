@@ -0,0 +1,48 @@
+package internet
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// useTempLocalDB replaces localDB with the database in the temporary dir of the test.
+func useTempLocalDB(t *testing.T) {
+	db, err := openBoltStore(filepath.Join(t.TempDir(), "blank.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := localDB
+	localDB = db
+	t.Cleanup(func() {
+		localDB = prev
+		db.Close()
+	})
+}
+
+func TestBoltStore(t *testing.T) {
+	useTempLocalDB(t)
+
+	if _, err := localDB.Get("test", "a"); err != errLocalNotFound {
+		t.Fatalf("expected errLocalNotFound, got %v", err)
+	}
+
+	localDB.Save("test", "b", []byte("2"))
+	localDB.Save("test", "a", []byte("1"))
+	if v, err := localDB.Get("test", "a"); err != nil || string(v) != "1" {
+		t.Fatalf("unexpected value %q, error: %v", v, err)
+	}
+
+	if keys, _ := localDB.GetAllKeys("test"); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+
+	if err := localDB.Delete("test", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if all, _ := localDB.GetAll("test"); len(all) != 1 || string(all[0]) != "2" {
+		t.Fatalf("unexpected values %q", all)
+	}
+}
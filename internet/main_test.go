@@ -0,0 +1,17 @@
+package internet
+
+import (
+	"os"
+	"testing"
+
+	"github.com/getblank/blank-sr/bdb"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+
+	// sessions store opens ./blank.db on import, tests use the temporary local DB only
+	bdb.BoltDB.Close()
+	os.Remove("blank.db")
+	os.Exit(code)
+}
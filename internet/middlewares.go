@@ -11,7 +11,10 @@ import (
 
 type ctxKey string
 
-const credKey ctxKey = "cred"
+const (
+	credKey  ctxKey = "cred"
+	storeKey ctxKey = "store"
+)
 
 // ErrSessionNotFound error
 var ErrSessionNotFound = errors.New("session not found")
@@ -25,6 +28,20 @@ func allowAnyOriginMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+// storeMiddleware sets the store of the route to the request context, so responses can be typed by its props.
+func storeMiddleware(storeName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, withStore(r, storeName))
+		})
+	}
+}
+
+// withStore returns the request with the store of the response items, e.g. the referenced store of the relation.
+func withStore(r *http.Request, storeName string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), storeKey, storeName))
+}
+
 func jwtAuthMiddleware(allowGuests bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
@@ -38,20 +55,20 @@ func jwtAuthMiddleware(allowGuests bool) func(http.Handler) http.Handler {
 					return
 				}
 
-				jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+				errorResponse(w, r, http.StatusUnauthorized, nil)
 
 				return
 			}
 
 			claims, err := extractClaimsFromJWT(accessToken)
 			if err != nil {
-				errorResponse(w, http.StatusForbidden, err)
+				errorResponse(w, r, http.StatusForbidden, err)
 				return
 			}
 
 			_, err = sessions.CheckSession(claims.SessionID)
 			if err != nil {
-				errorResponse(w, http.StatusForbidden, ErrSessionNotFound)
+				errorResponse(w, r, http.StatusForbidden, ErrSessionNotFound)
 				return
 			}
 
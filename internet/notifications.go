@@ -0,0 +1,464 @@
+package internet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+
+	"github.com/getblank/blank-one/sessions"
+)
+
+const (
+	notificationOwnerProp = "_ownerId"
+	notificationReadProp  = "read"
+	notificationEventName = "notification"
+
+	// notificationsReadPageSize is the number of notifications marked as read by one find task.
+	notificationsReadPageSize = 100
+)
+
+var inboxStreams = &inboxHub{streams: map[string]map[chan []byte]struct{}{}}
+
+// inboxHub delivers new notifications to the inbox SSE streams of the user.
+type inboxHub struct {
+	streams map[string]map[chan []byte]struct{}
+	sync.RWMutex
+}
+
+func (h *inboxHub) subscribe(userID interface{}) chan []byte {
+	ch := make(chan []byte, sseStreamBufferSize)
+	key := fmt.Sprint(userID)
+
+	h.Lock()
+	defer h.Unlock()
+
+	if h.streams[key] == nil {
+		h.streams[key] = map[chan []byte]struct{}{}
+	}
+	h.streams[key][ch] = struct{}{}
+
+	return ch
+}
+
+func (h *inboxHub) unsubscribe(userID interface{}, ch chan []byte) {
+	key := fmt.Sprint(userID)
+
+	h.Lock()
+	defer h.Unlock()
+
+	delete(h.streams[key], ch)
+	if len(h.streams[key]) == 0 {
+		delete(h.streams, key)
+	}
+}
+
+func (h *inboxHub) publish(userID interface{}, data []byte) {
+	h.RLock()
+	defer h.RUnlock()
+
+	for ch := range h.streams[fmt.Sprint(userID)] {
+		select {
+		case ch <- data:
+		default:
+			log.Warnf("[inbox] stream buffer of user %v is full, notification is dropped", userID)
+		}
+	}
+}
+
+// createNotificationRESTAPI creates inbox routes for the store of type "notification":
+//
+//	GET  /{store}/_inbox?read=false  notifications of the user, newest first
+//	GET  /{store}/_inbox/_count      count of unread notifications
+//	GET  /{store}/_inbox/_stream     SSE stream of new notifications
+//	POST /{store}/_inbox/_read       marks all notifications as read
+//	POST /{store}/_inbox/{id}/_read  marks the notification as read
+func createNotificationRESTAPI(r chi.Router, store config.Store, baseURI, lowerBaseURI string) {
+	routes := []struct {
+		method  string
+		path    string
+		handler http.HandlerFunc
+	}{
+		{http.MethodGet, "/_inbox", restInboxHandler(store)},
+		{http.MethodGet, "/_inbox/_count", restInboxCountHandler(store)},
+		{http.MethodGet, "/_inbox/_stream", restInboxStreamHandler(store)},
+		{http.MethodPost, "/_inbox/_read", restInboxReadAllHandler(store)},
+		{http.MethodPost, "/_inbox/{id}/_read", restInboxReadHandler(store)},
+	}
+
+	for _, route := range routes {
+		uri := baseURI + route.path
+		lowerURI := lowerBaseURI + route.path
+		r.Method(route.method, uri, route.handler)
+		log.Debugf("Created %s inbox REST method %s", route.method, uri)
+		if uri != lowerURI {
+			r.Method(route.method, lowerURI, route.handler)
+			log.Debugf("Created %s inbox REST method %s", route.method, lowerURI)
+		}
+	}
+}
+
+// inboxQuery returns query of the user notifications. Read param filters read or unread notifications.
+func inboxQuery(userID interface{}, read string) (map[string]interface{}, error) {
+	query := map[string]interface{}{notificationOwnerProp: userID}
+	if len(read) == 0 {
+		return query, nil
+	}
+
+	isRead, err := strconv.ParseBool(read)
+	if err != nil {
+		return nil, fmt.Errorf("invalid read param")
+	}
+
+	if isRead {
+		query[notificationReadProp] = true
+	} else {
+		query[notificationReadProp] = map[string]interface{}{"$ne": true}
+	}
+
+	return query, nil
+}
+
+func findNotifications(ctx context.Context, cred credentials, storeName string, query map[string]interface{}, skip, take int, props []string) (map[string]interface{}, error) {
+	findQuery := map[string]interface{}{"query": query, "skip": skip, "take": take, "orderBy": "-createdAt"}
+	if props != nil {
+		findQuery["props"] = props
+	}
+
+	t := taskq.Task{
+		Type:      taskq.DbFind,
+		UserID:    cred.userID,
+		Store:     storeName,
+		Arguments: map[string]interface{}{"query": findQuery},
+	}
+	if cred.claims != nil {
+		t.Arguments["tokenInfo"] = cred.claims.toMap()
+	}
+
+	res, err := pushTask(ctx, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	result, _ := res.(map[string]interface{})
+	return result, nil
+}
+
+func restInboxHandler(store config.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cred, ok := inboxCredentials(w, r)
+		if !ok {
+			return
+		}
+
+		params := r.URL.Query()
+		query, err := inboxQuery(cred.userID, params.Get("read"))
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		take, err := parsePageSize(params.Get("take"), getStoreSettings(store.Store))
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var skip int
+		if s := params.Get("skip"); len(s) > 0 {
+			if skip, err = strconv.Atoi(s); err != nil || skip < 0 {
+				errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("invalid skip param"))
+				return
+			}
+		}
+
+		result, err := findNotifications(r.Context(), cred, store.Store, query, skip, take, nil)
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		items, _ := result["items"].([]interface{})
+		page := listPage{Items: items, Take: take}
+		if page.Items == nil {
+			page.Items = []interface{}{}
+		}
+
+		if count, ok := result["count"].(float64); ok {
+			n := int(count)
+			page.Count = &n
+			w.Header().Set(headerTotalCount, strconv.Itoa(n))
+		}
+
+		apiResponse(w, r, http.StatusOK, page)
+	}
+}
+
+func restInboxCountHandler(store config.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cred, ok := inboxCredentials(w, r)
+		if !ok {
+			return
+		}
+
+		query, _ := inboxQuery(cred.userID, "false")
+		result, err := findNotifications(r.Context(), cred, store.Store, query, 0, 1, []string{"_id"})
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		count, _ := result["count"].(float64)
+		apiResponse(w, r, http.StatusOK, map[string]interface{}{"unread": int(count)})
+	}
+}
+
+func restInboxReadHandler(store config.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cred, ok := inboxCredentials(w, r)
+		if !ok {
+			return
+		}
+
+		id := chi.URLParam(r, "id")
+		t := taskq.Task{
+			Type:      taskq.DbGet,
+			UserID:    cred.userID,
+			Store:     store.Store,
+			Arguments: map[string]interface{}{"_id": id},
+		}
+		if cred.claims != nil {
+			t.Arguments["tokenInfo"] = cred.claims.toMap()
+		}
+
+		res, err := pushTask(r.Context(), &t)
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		// notifications of other users are not found for the user
+		item, _ := res.(map[string]interface{})
+		if item == nil || fmt.Sprint(item[notificationOwnerProp]) != fmt.Sprint(cred.userID) {
+			errorResponse(w, r, 0, newAPIError(http.StatusNotFound, "not_found", "notification not found"))
+			return
+		}
+
+		if err := markNotificationRead(r.Context(), cred, store.Store, id); err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		apiResponse(w, r, http.StatusOK, http.StatusText(http.StatusOK))
+	}
+}
+
+// restInboxReadAllHandler marks all unread notifications of the user as read and returns number of marked notifications.
+func restInboxReadAllHandler(store config.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cred, ok := inboxCredentials(w, r)
+		if !ok {
+			return
+		}
+
+		query, _ := inboxQuery(cred.userID, "false")
+		// marked notifications are kept to stop if workers leave them unread
+		marked := map[string]struct{}{}
+		for {
+			result, err := findNotifications(r.Context(), cred, store.Store, query, 0, notificationsReadPageSize, []string{"_id"})
+			if err != nil {
+				errorResponse(w, r, 0, err)
+				return
+			}
+
+			items, _ := result["items"].([]interface{})
+			var markedOnPage int
+			for _, el := range items {
+				item, _ := el.(map[string]interface{})
+				id := fmt.Sprint(item["_id"])
+				if _, ok := marked[id]; ok {
+					continue
+				}
+
+				if err := markNotificationRead(r.Context(), cred, store.Store, item["_id"]); err != nil {
+					errorResponse(w, r, 0, err)
+					return
+				}
+
+				marked[id] = struct{}{}
+				markedOnPage++
+			}
+
+			if len(items) < notificationsReadPageSize || markedOnPage == 0 {
+				break
+			}
+		}
+
+		apiResponse(w, r, http.StatusOK, map[string]interface{}{"marked": len(marked)})
+	}
+}
+
+func markNotificationRead(ctx context.Context, cred credentials, storeName string, id interface{}) error {
+	t := taskq.Task{
+		Type:   taskq.DbSet,
+		UserID: cred.userID,
+		Store:  storeName,
+		Arguments: map[string]interface{}{
+			"item": map[string]interface{}{"_id": id, notificationReadProp: true},
+		},
+	}
+	if cred.claims != nil {
+		t.Arguments["tokenInfo"] = cred.claims.toMap()
+	}
+
+	_, err := pushTask(ctx, &t)
+	return err
+}
+
+// restInboxStreamHandler streams new notifications of the user as SSE "notification" events.
+func restInboxStreamHandler(store config.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cred, ok := inboxCredentials(w, r)
+		if !ok {
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+			return
+		}
+
+		ch := inboxStreams.subscribe(cred.userID)
+		defer inboxStreams.unsubscribe(cred.userID, ch)
+
+		w.Header().Set(headerContentType, textEventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(sseHeartbeatPeriod)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case data := <-ch:
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", notificationEventName, data); err != nil {
+					log.Debugf("[rest inbox] write error: %v", err)
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := sessions.CheckSession(cred.sessionID); err != nil {
+					return
+				}
+
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					log.Debugf("[rest inbox] write error: %v", err)
+					return
+				}
+			}
+
+			flusher.Flush()
+		}
+	}
+}
+
+func inboxCredentials(w http.ResponseWriter, r *http.Request) (credentials, bool) {
+	c := r.Context().Value(credKey)
+	if c == nil {
+		log.Warn("[rest inbox]: no cred in echo context")
+		errorResponse(w, r, http.StatusUnauthorized, nil)
+		return credentials{}, false
+	}
+
+	cred, ok := c.(credentials)
+	if !ok {
+		log.Warn("[rest inbox]: invalid cred in echo context")
+		errorResponse(w, r, http.StatusUnauthorized, nil)
+		return credentials{}, false
+	}
+
+	return cred, true
+}
+
+// onNotificationEvent delivers notifications from the store events of notification stores to their owners
+// over com.user WAMP subscriptions and inbox SSE streams.
+func onNotificationEvent(uri string, event interface{}) {
+	if !strings.HasPrefix(uri, uriSubStores+".") {
+		return
+	}
+
+	storeName := strings.TrimPrefix(uri, uriSubStores+".")
+	if store, ok := getStoreConfig(storeName); !ok || store.Type != config.ObjNotification {
+		return
+	}
+
+	for _, item := range notificationItems(event) {
+		ownerID, ok := item[notificationOwnerProp]
+		if !ok || ownerID == nil {
+			continue
+		}
+
+		payload := map[string]interface{}{"event": notificationEventName, "store": storeName, "item": item}
+		if subscribers := sessions.UserSubscribers(ownerID, uriSubUser); len(subscribers) > 0 {
+			onSREvent(uriSubUser, payload, subscribers)
+		}
+
+		data, err := json.Marshal(payload)
+		if err != nil {
+			log.Errorf("[inbox] can't marshal notification, error: %v", err)
+			continue
+		}
+
+		inboxStreams.publish(ownerID, data)
+	}
+}
+
+// notificationItems extracts new notifications from the store event. Event may be the item itself
+// or have the item or list of items in the data field. Deleted and read notifications are skipped.
+func notificationItems(event interface{}) []map[string]interface{} {
+	m, ok := event.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if e, _ := m["event"].(string); e == "delete" || e == "deleted" {
+		return nil
+	}
+
+	var values []interface{}
+	switch data := m["data"].(type) {
+	case map[string]interface{}:
+		values = []interface{}{data}
+	case []interface{}:
+		values = data
+	default:
+		values = []interface{}{m}
+	}
+
+	var res []map[string]interface{}
+	for _, v := range values {
+		item, ok := v.(map[string]interface{})
+		if !ok || item[notificationOwnerProp] == nil {
+			continue
+		}
+
+		if read, _ := item[notificationReadProp].(bool); read {
+			continue
+		}
+
+		res = append(res, item)
+	}
+
+	return res
+}
@@ -0,0 +1,45 @@
+package internet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInboxQuery(t *testing.T) {
+	q, err := inboxQuery("u1", "")
+	if err != nil || !reflect.DeepEqual(q, map[string]interface{}{"_ownerId": "u1"}) {
+		t.Errorf("unexpected query %v, error: %v", q, err)
+	}
+
+	q, err = inboxQuery("u1", "false")
+	if err != nil || !reflect.DeepEqual(q, map[string]interface{}{"_ownerId": "u1", "read": map[string]interface{}{"$ne": true}}) {
+		t.Errorf("unexpected unread query %v, error: %v", q, err)
+	}
+
+	if _, err = inboxQuery("u1", "maybe"); err == nil {
+		t.Error("invalid read param was accepted")
+	}
+}
+
+func TestNotificationItems(t *testing.T) {
+	unread := map[string]interface{}{"_id": "1", "_ownerId": "u1"}
+	read := map[string]interface{}{"_id": "2", "_ownerId": "u1", "read": true}
+	noOwner := map[string]interface{}{"_id": "3"}
+
+	testData := []struct {
+		event    interface{}
+		expected int
+	}{
+		{unread, 1},
+		{map[string]interface{}{"event": "create", "data": unread}, 1},
+		{map[string]interface{}{"event": "update", "data": []interface{}{unread, read, noOwner}}, 1},
+		{map[string]interface{}{"event": "delete", "data": unread}, 0},
+		{"not an object", 0},
+	}
+
+	for i, v := range testData {
+		if res := notificationItems(v.event); len(res) != v.expected {
+			t.Errorf("#%d: got %d notifications, expected: %d", i, len(res), v.expected)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package internet
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerLink       = "Link"
+	headerTotalCount = "X-Total-Count"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor is an opaque position in the ordered list of store items.
+// It holds values of the ordering props and _id of the boundary item.
+type pageCursor struct {
+	OrderBy string        `json:"o"`
+	Values  []interface{} `json:"v,omitempty"`
+	ID      interface{}   `json:"i"`
+	Prev    bool          `json:"p,omitempty"`
+}
+
+type sortField struct {
+	prop string
+	desc bool
+}
+
+// listPage is the response envelope of the REST list endpoints.
+type listPage struct {
+	Items      []interface{} `json:"items"`
+	Count      *int          `json:"count,omitempty"`
+	Take       int           `json:"take"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+	PrevCursor string        `json:"prevCursor,omitempty"`
+}
+
+func encodeCursor(c pageCursor) string {
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		log.Errorf("Can't marshal page cursor, error: %v", err)
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	decoded, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+
+	if err := json.Unmarshal(decoded, &c); err != nil || c.ID == nil {
+		return c, errInvalidCursor
+	}
+
+	return c, nil
+}
+
+// parseOrderBy splits comma separated orderBy into sort fields.
+// Fields prefixed with "-" are sorted in descending order.
+func parseOrderBy(orderBy string) []sortField {
+	var res []sortField
+	for _, f := range strings.Split(orderBy, ",") {
+		f = strings.TrimSpace(f)
+		if len(f) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(f, "-") {
+			res = append(res, sortField{f[1:], true})
+			continue
+		}
+
+		res = append(res, sortField{strings.TrimPrefix(f, "+"), false})
+	}
+
+	return res
+}
+
+// reverseOrderBy returns orderBy with the opposite direction of every field.
+func reverseOrderBy(orderBy string) string {
+	fields := parseOrderBy(orderBy)
+	res := make([]string, len(fields))
+	for i, f := range fields {
+		if f.desc {
+			res[i] = f.prop
+		} else {
+			res[i] = "-" + f.prop
+		}
+	}
+
+	return strings.Join(res, ",")
+}
+
+// stableOrderBy returns orderBy with _id appended as the last sort field if it is missing,
+// so items with equal ordering values keep the same order on every page.
+// _id is sorted in the direction of the last field to match cursorQuery.
+func stableOrderBy(orderBy string) string {
+	fields := parseOrderBy(orderBy)
+	for _, f := range fields {
+		if f.prop == "_id" {
+			return orderBy
+		}
+	}
+
+	if len(fields) == 0 {
+		return "_id"
+	}
+
+	if fields[len(fields)-1].desc {
+		return orderBy + ",-_id"
+	}
+
+	return orderBy + ",_id"
+}
+
+// cursorQuery returns the query that selects items placed after the cursor position
+// in the direction of the provided orderBy. Items with equal ordering values are ordered by _id.
+func cursorQuery(c pageCursor, orderBy string) map[string]interface{} {
+	fields := parseOrderBy(orderBy)
+	var or []interface{}
+	equal := map[string]interface{}{}
+	idOp := "$gt"
+	for i, f := range fields {
+		op := "$gt"
+		if f.desc {
+			op = "$lt"
+		}
+
+		idOp = op
+		if f.prop == "_id" {
+			break
+		}
+
+		var value interface{}
+		if i < len(c.Values) {
+			value = c.Values[i]
+		}
+
+		cond := map[string]interface{}{f.prop: map[string]interface{}{op: value}}
+		for k, v := range equal {
+			cond[k] = v
+		}
+
+		or = append(or, cond)
+		equal[f.prop] = value
+	}
+
+	last := map[string]interface{}{"_id": map[string]interface{}{idOp: c.ID}}
+	for k, v := range equal {
+		last[k] = v
+	}
+
+	if len(or) == 0 {
+		return last
+	}
+
+	return map[string]interface{}{"$or": append(or, last)}
+}
+
+// mergeQueries combines queries with $and operator. Nil queries are skipped.
+func mergeQueries(queries ...map[string]interface{}) map[string]interface{} {
+	var and []interface{}
+	for _, q := range queries {
+		if len(q) > 0 {
+			and = append(and, q)
+		}
+	}
+
+	switch len(and) {
+	case 0:
+		return nil
+	case 1:
+		return and[0].(map[string]interface{})
+	}
+
+	return map[string]interface{}{"$and": and}
+}
+
+// cursorForItem makes encoded cursor pointed to the provided item.
+func cursorForItem(item interface{}, orderBy string, prev bool) string {
+	c, ok := newPageCursor(item, orderBy, prev)
+	if !ok {
+		return ""
+	}
+
+	return encodeCursor(c)
+}
+
+func newPageCursor(item interface{}, orderBy string, prev bool) (pageCursor, bool) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return pageCursor{}, false
+	}
+
+	c := pageCursor{OrderBy: orderBy, ID: m["_id"], Prev: prev}
+	for _, f := range parseOrderBy(orderBy) {
+		if f.prop == "_id" {
+			break
+		}
+
+		c.Values = append(c.Values, lookupProp(m, f.prop))
+	}
+
+	return c, true
+}
+
+// lookupProp returns value of the prop from item. Nested props are separated by dots.
+func lookupProp(item map[string]interface{}, prop string) interface{} {
+	path := strings.Split(prop, ".")
+	for i, p := range path {
+		v, ok := item[p]
+		if !ok || i == len(path)-1 {
+			return v
+		}
+
+		if item, ok = v.(map[string]interface{}); !ok {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// parsePageSize returns page size from the take query param limited by store settings.
+func parsePageSize(take string, s storeSettings) (int, error) {
+	if len(take) == 0 {
+		return s.DefaultPageSize, nil
+	}
+
+	n, err := strconv.Atoi(take)
+	if err != nil {
+		return 0, err
+	}
+
+	if n <= 0 {
+		return 0, fmt.Errorf("take must be positive, got %d", n)
+	}
+
+	if n > s.MaxPageSize {
+		n = s.MaxPageSize
+	}
+
+	return n, nil
+}
+
+// setLinkHeader sets RFC 8288 Link header with next and prev pages of the list.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, page listPage) {
+	var links []string
+	if len(page.NextCursor) > 0 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, page.NextCursor)))
+	}
+
+	if len(page.PrevCursor) > 0 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(r, page.PrevCursor)))
+	}
+
+	if len(links) > 0 {
+		w.Header().Set(headerLink, strings.Join(links, ", "))
+	}
+}
+
+func pageURL(r *http.Request, cursor string) string {
+	u := url.URL{Path: r.URL.Path}
+	q := r.URL.Query()
+	q.Del("skip")
+	q.Del("access_token")
+	q.Set("cursor", cursor)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
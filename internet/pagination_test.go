@@ -0,0 +1,333 @@
+package internet
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+func TestCursorEncoding(t *testing.T) {
+	c := pageCursor{OrderBy: "-name", Values: []interface{}{"John"}, ID: "42", Prev: true}
+	res, err := decodeCursor(encodeCursor(c))
+	if err != nil {
+		t.Fatalf("decodeCursor error: %v", err)
+	}
+
+	if !reflect.DeepEqual(res, c) {
+		t.Fatalf("decodeCursor(encodeCursor(%v)) => %v", c, res)
+	}
+
+	if _, err := decodeCursor("not a cursor"); err != errInvalidCursor {
+		t.Fatalf("decodeCursor with invalid cursor returns %v, expected: %v", err, errInvalidCursor)
+	}
+}
+
+func TestStableOrderBy(t *testing.T) {
+	testData := []struct {
+		orderBy string
+		res     string
+	}{
+		{"", "_id"},
+		{"name", "name,_id"},
+		{"name,-age", "name,-age,-_id"},
+		{"-_id", "-_id"},
+		{"_id,name", "_id,name"},
+	}
+
+	for _, v := range testData {
+		if res := stableOrderBy(v.orderBy); res != v.res {
+			t.Errorf("stableOrderBy(%q) => %q, expected: %q", v.orderBy, res, v.res)
+		}
+	}
+}
+
+func TestCursorQuery(t *testing.T) {
+	c := pageCursor{OrderBy: "name,-age", Values: []interface{}{"John", 30.0}, ID: "42"}
+	testData := []struct {
+		orderBy string
+		res     map[string]interface{}
+	}{
+		{
+			"_id",
+			map[string]interface{}{"_id": map[string]interface{}{"$gt": "42"}},
+		},
+		{
+			"-_id",
+			map[string]interface{}{"_id": map[string]interface{}{"$lt": "42"}},
+		},
+		{
+			"name",
+			map[string]interface{}{"$or": []interface{}{
+				map[string]interface{}{"name": map[string]interface{}{"$gt": "John"}},
+				map[string]interface{}{"name": "John", "_id": map[string]interface{}{"$gt": "42"}},
+			}},
+		},
+		{
+			"-name",
+			map[string]interface{}{"$or": []interface{}{
+				map[string]interface{}{"name": map[string]interface{}{"$lt": "John"}},
+				map[string]interface{}{"name": "John", "_id": map[string]interface{}{"$lt": "42"}},
+			}},
+		},
+		{
+			"name,-age",
+			map[string]interface{}{"$or": []interface{}{
+				map[string]interface{}{"name": map[string]interface{}{"$gt": "John"}},
+				map[string]interface{}{"name": "John", "age": map[string]interface{}{"$lt": 30.0}},
+				map[string]interface{}{"name": "John", "age": 30.0, "_id": map[string]interface{}{"$lt": "42"}},
+			}},
+		},
+	}
+
+	for i, v := range testData {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			res := cursorQuery(c, v.orderBy)
+			if !reflect.DeepEqual(res, v.res) {
+				t.Fatalf("cursorQuery(%v, %s) => %v, expected: %v", c, v.orderBy, res, v.res)
+			}
+		})
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	s := storeSettings{DefaultPageSize: 20, MaxPageSize: 100}
+	testData := []struct {
+		in    string
+		res   int
+		isErr bool
+	}{
+		{"", 20, false},
+		{"5", 5, false},
+		{"1000", 100, false},
+		{"0", 0, true},
+		{"abc", 0, true},
+	}
+
+	for i, v := range testData {
+		t.Run(fmt.Sprintf("#%d", i), func(t *testing.T) {
+			res, err := parsePageSize(v.in, s)
+			if (err != nil) != v.isErr {
+				t.Fatalf("parsePageSize(%s) error: %v", v.in, err)
+			}
+
+			if res != v.res {
+				t.Fatalf("parsePageSize(%s) => %d, expected: %d", v.in, res, v.res)
+			}
+		})
+	}
+}
+
+func TestRestListPagesWithEqualOrderValues(t *testing.T) {
+	store := config.Store{Store: "pagingTestOrders"}
+	rt := chi.NewRouter()
+	rt.With(withTestCred).Get("/orders", restGetAllDocumentsHandler(store))
+
+	// the worker keeps equal items in the insertion order, which differs from the _id order
+	items := []map[string]interface{}{
+		{"_id": "5", "rank": float64(1)},
+		{"_id": "4", "rank": float64(1)},
+		{"_id": "3", "rank": float64(1)},
+		{"_id": "2", "rank": float64(1)},
+		{"_id": "1", "rank": float64(0)},
+	}
+
+	getPage := func(query string) map[string]interface{} {
+		served := serveTasks(1, func(task *taskq.Task) (interface{}, string) {
+			return fakeFind(items, task), ""
+		})
+
+		r := httptest.NewRequest("GET", "/orders?"+query, nil)
+		r.Header.Set("Accept", mediaTypeMsgPack)
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		task := <-served
+		findQuery, _ := task.Arguments["query"].(map[string]interface{})
+		if orderBy, _ := findQuery["orderBy"].(string); !strings.HasSuffix(orderBy, "_id") {
+			t.Fatalf("orderBy %q sent to worker has no _id", orderBy)
+		}
+
+		var page map[string]interface{}
+		if err := msgpack.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+
+		return page
+	}
+
+	var ids []string
+	var pages []map[string]interface{}
+	query := "orderBy=rank&take=2"
+	for len(pages) < len(items) {
+		page := getPage(query)
+		pages = append(pages, page)
+		ids = append(ids, pageIDs(page)...)
+		next, _ := page["nextCursor"].(string)
+		if len(next) == 0 {
+			break
+		}
+
+		query = "orderBy=rank&take=2&cursor=" + url.QueryEscape(next)
+	}
+
+	expected := []string{"1", "2", "3", "4", "5"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("paged ids %v, expected: %v", ids, expected)
+	}
+
+	prev, _ := pages[len(pages)-1]["prevCursor"].(string)
+	if len(prev) == 0 {
+		t.Fatal("no prevCursor on the last page")
+	}
+
+	if res := pageIDs(getPage("orderBy=rank&take=2&cursor=" + url.QueryEscape(prev))); !reflect.DeepEqual(res, []string{"3", "4"}) {
+		t.Fatalf("prev page ids %v, expected: [3 4]", res)
+	}
+}
+
+func pageIDs(page map[string]interface{}) []string {
+	items, _ := page["items"].([]interface{})
+	ids := make([]string, len(items))
+	for i, item := range items {
+		m, _ := item.(map[string]interface{})
+		ids[i], _ = m["_id"].(string)
+	}
+
+	return ids
+}
+
+// fakeFind selects items for the DbFind task as the worker does. Items are filtered by the query and
+// sorted by orderBy keeping the source order of the equal items, then the requested page and props are returned.
+func fakeFind(items []map[string]interface{}, task *taskq.Task) map[string]interface{} {
+	findQuery, _ := task.Arguments["query"].(map[string]interface{})
+	query, _ := findQuery["query"].(map[string]interface{})
+	orderBy, _ := findQuery["orderBy"].(string)
+	props, _ := findQuery["props"].([]string)
+	skip, _ := findQuery["skip"].(int)
+	take, _ := findQuery["take"].(int)
+
+	var found []map[string]interface{}
+	for _, item := range items {
+		if matchFakeQuery(item, query) {
+			found = append(found, item)
+		}
+	}
+
+	fields := parseOrderBy(orderBy)
+	sort.SliceStable(found, func(i, j int) bool {
+		for _, f := range fields {
+			c := compareFakeValues(lookupProp(found[i], f.prop), lookupProp(found[j], f.prop))
+			if c != 0 {
+				return (c < 0) != f.desc
+			}
+		}
+
+		return false
+	})
+
+	res := []interface{}{}
+	for i := skip; i < len(found) && len(res) < take; i++ {
+		item := found[i]
+		if len(props) > 0 {
+			item = map[string]interface{}{"_id": found[i]["_id"]}
+			for _, p := range props {
+				item[p] = found[i][p]
+			}
+		}
+
+		res = append(res, item)
+	}
+
+	return map[string]interface{}{"items": res, "count": float64(len(found))}
+}
+
+func matchFakeQuery(item map[string]interface{}, query map[string]interface{}) bool {
+	for k, v := range query {
+		conditions, _ := v.([]interface{})
+		switch k {
+		case "$and":
+			for _, c := range conditions {
+				if q, _ := c.(map[string]interface{}); !matchFakeQuery(item, q) {
+					return false
+				}
+			}
+		case "$or":
+			var matched bool
+			for _, c := range conditions {
+				if q, _ := c.(map[string]interface{}); matchFakeQuery(item, q) {
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return false
+			}
+		default:
+			ops, ok := v.(map[string]interface{})
+			if !ok {
+				ops = map[string]interface{}{"$eq": v}
+			}
+
+			for op, value := range ops {
+				if op == "$in" {
+					values, _ := value.([]interface{})
+					if indexOfID(values, lookupProp(item, k)) < 0 {
+						return false
+					}
+
+					continue
+				}
+
+				c := compareFakeValues(lookupProp(item, k), value)
+				if (op == "$eq" && c != 0) || (op == "$gt" && c <= 0) || (op == "$lt" && c >= 0) {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// compareFakeValues compares numbers and strings, nil is less than any other value.
+func compareFakeValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+
+			return 0
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
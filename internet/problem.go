@@ -0,0 +1,190 @@
+package internet
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/middleware"
+
+	"github.com/getblank/blank-router/berrors"
+	"github.com/getblank/blank-router/taskq"
+)
+
+const (
+	applicationProblemJSON = "application/problem+json; charset=utf-8"
+	headerRequestID        = "X-Request-Id"
+	problemTypeDefault     = "about:blank"
+)
+
+// apiError is the structured error of the worker or blank-one itself.
+// Workers send it to the task.error RPC as an object:
+//
+//	{"status": 422, "code": "validation_failed", "message": "invalid item", "errors": [{"field": "name", "code": "required", "message": "name is required"}]}
+//
+// Legacy string errors are still supported.
+type apiError struct {
+	Status  int          `json:"status,omitempty"`
+	Code    string       `json:"code,omitempty"`
+	Message string       `json:"message"`
+	Fields  []fieldError `json:"errors,omitempty"`
+}
+
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, code, message string, fields ...fieldError) *apiError {
+	return &apiError{Status: status, Code: code, Message: message, Fields: fields}
+}
+
+// problem is the RFC 7807 problem details object.
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+// toAPIError maps any error returned by handlers or workers to apiError. This is the only place where
+// errors are converted to HTTP statuses. When status is not 0, it is used for errors without their own status;
+// otherwise the status is guessed from the legacy worker error text.
+func toAPIError(status int, err error) *apiError {
+	if err == nil {
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+
+		return newAPIError(status, "", http.StatusText(status))
+	}
+
+	if e, ok := err.(*apiError); ok {
+		res := *e
+		if res.Status == 0 {
+			res.Status = status
+		}
+
+		if res.Status == 0 {
+			res.Status = http.StatusInternalServerError
+		}
+
+		return &res
+	}
+
+	if e, ok := parseWorkerError(err.Error()); ok {
+		return toAPIError(status, e)
+	}
+
+	switch err {
+	case berrors.ErrInvalidArguments:
+		return newAPIError(http.StatusBadRequest, "invalid_arguments", err.Error())
+	case berrors.ErrForbidden:
+		return newAPIError(http.StatusForbidden, "forbidden", err.Error())
+	case berrors.ErrNotConnected:
+		return newAPIError(http.StatusServiceUnavailable, "not_connected", err.Error())
+	case taskq.ErrTimeout:
+		return newAPIError(http.StatusGatewayTimeout, "timeout", err.Error())
+	}
+
+	switch err.(type) {
+	case *filterError:
+		return newAPIError(http.StatusBadRequest, "invalid_query", err.Error())
+	}
+
+	if status != 0 {
+		return newAPIError(status, "", err.Error())
+	}
+
+	text := err.Error()
+	switch {
+	case strings.EqualFold(text, "not found"):
+		return newAPIError(http.StatusNotFound, "not_found", text)
+	case strings.EqualFold(text, "unauthorized"), strings.EqualFold(text, berrors.ErrForbidden.Error()):
+		return newAPIError(http.StatusForbidden, "forbidden", text)
+	case strings.Contains(text, "E11000"), strings.Contains(strings.ToLower(text), "already exists"):
+		// duplicate key error of the insert task
+		return newAPIError(http.StatusConflict, "already_exists", text)
+	}
+
+	// legacy workers send errors like "404 item not found"
+	if fields := strings.SplitN(text, " ", 2); len(fields) > 1 {
+		if code, err := strconv.Atoi(fields[0]); err == nil && code >= 400 && code < 600 {
+			return newAPIError(code, "", fields[1])
+		}
+	}
+
+	return newAPIError(http.StatusInternalServerError, "", text)
+}
+
+// parseWorkerError decodes structured worker error which is passed through the task queue as JSON object.
+func parseWorkerError(text string) (*apiError, bool) {
+	if !strings.HasPrefix(text, "{") {
+		return nil, false
+	}
+
+	var e apiError
+	if err := json.Unmarshal([]byte(text), &e); err != nil {
+		return nil, false
+	}
+
+	if len(e.Message) == 0 && e.Status == 0 {
+		return nil, false
+	}
+
+	if len(e.Message) == 0 {
+		e.Message = http.StatusText(e.Status)
+	}
+
+	return &e, true
+}
+
+func newProblem(r *http.Request, e *apiError) problem {
+	p := problem{
+		Type:   problemTypeDefault,
+		Title:  http.StatusText(e.Status),
+		Status: e.Status,
+		Detail: e.Message,
+		Code:   e.Code,
+		Errors: e.Fields,
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+		p.RequestID = middleware.GetReqID(r.Context())
+	}
+
+	if p.Detail == p.Title {
+		p.Detail = ""
+	}
+
+	return p
+}
+
+func problemResponse(w http.ResponseWriter, r *http.Request, e *apiError) {
+	p := newProblem(r, e)
+	encoded, err := json.Marshal(p)
+	if err != nil {
+		log.Errorf("Can't marshal problem details, error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if len(p.RequestID) > 0 {
+		w.Header().Set(headerRequestID, p.RequestID)
+	}
+
+	w.Header().Set(headerContentType, applicationProblemJSON)
+	w.WriteHeader(p.Status)
+	if _, err := w.Write(encoded); err != nil {
+		log.Debugf("[problemResponse] write error: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package internet
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/getblank/blank-router/taskq"
+)
+
+func TestToAPIError(t *testing.T) {
+	testData := []struct {
+		status int
+		err    error
+		res    *apiError
+	}{
+		{0, errors.New("not found"), newAPIError(http.StatusNotFound, "not_found", "not found")},
+		{0, errors.New("Unauthorized"), newAPIError(http.StatusForbidden, "forbidden", "Unauthorized")},
+		{0, errors.New("409 item exists"), newAPIError(http.StatusConflict, "", "item exists")},
+		{0, errors.New("E11000 duplicate key error"), newAPIError(http.StatusConflict, "already_exists", "E11000 duplicate key error")},
+		{0, errors.New("2 items failed"), newAPIError(http.StatusInternalServerError, "", "2 items failed")},
+		{0, taskq.ErrTimeout, newAPIError(http.StatusGatewayTimeout, "timeout", "timeout")},
+		{http.StatusBadRequest, errors.New("not found"), newAPIError(http.StatusBadRequest, "", "not found")},
+		{http.StatusUnauthorized, nil, newAPIError(http.StatusUnauthorized, "", "Unauthorized")},
+		{
+			0,
+			errors.New(`{"status":422,"code":"validation_failed","message":"invalid item","errors":[{"field":"name","code":"required","message":"name is required"}]}`),
+			newAPIError(http.StatusUnprocessableEntity, "validation_failed", "invalid item", fieldError{"name", "required", "name is required"}),
+		},
+		{
+			http.StatusForbidden,
+			errors.New(`{"code":"bad_password","message":"invalid password"}`),
+			newAPIError(http.StatusForbidden, "bad_password", "invalid password"),
+		},
+	}
+
+	for i, v := range testData {
+		res := toAPIError(v.status, v.err)
+		if !reflect.DeepEqual(res, v.res) {
+			t.Fatalf("%d: expected %+v, got %+v", i, v.res, res)
+		}
+	}
+}
+
+func TestProblemResponse(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/users/1", nil)
+	w := httptest.NewRecorder()
+	errorResponse(w, r, 0, errors.New("not found"))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", w.Code)
+	}
+
+	if ct := w.Header().Get(headerContentType); ct != applicationProblemJSON {
+		t.Fatalf("expected content type %q, got %q", applicationProblemJSON, ct)
+	}
+
+	var p problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := problem{Type: problemTypeDefault, Title: "Not Found", Status: http.StatusNotFound, Detail: "not found", Instance: "/api/v1/users/1", Code: "not_found"}
+	if !reflect.DeepEqual(p, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, p)
+	}
+}
@@ -0,0 +1,261 @@
+package internet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-chi/chi"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+const (
+	// taskDbTransition moves the item of the process store to another state. Workers check if the move is allowed.
+	taskDbTransition = "dbTransition"
+
+	stateProp           = "_state"
+	stateCountsParallel = 4
+)
+
+type processState struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	NavOrder int    `json:"navOrder"`
+	Count    *int   `json:"count,omitempty"`
+}
+
+type transitionRequest struct {
+	State string                 `json:"state"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+// createProcessRESTAPI creates state routes for the store of type "process".
+func createProcessRESTAPI(r chi.Router, store config.Store, baseURI, lowerBaseURI string) {
+	statesURI := baseURI + "/_states"
+	lowerStatesURI := lowerBaseURI + "/_states"
+	r.Get(statesURI, restStatesHandler(store))
+	log.Debugf("Created GET states REST method %s", statesURI)
+	if statesURI != lowerStatesURI {
+		r.Get(lowerStatesURI, restStatesHandler(store))
+		log.Debugf("Created GET states REST method %s", lowerStatesURI)
+	}
+
+	transitionURI := baseURI + "/{id}/_transition"
+	lowerTransitionURI := lowerBaseURI + "/{id}/_transition"
+	r.Post(transitionURI, idempotencyHandler(restTransitionHandler(store)))
+	log.Debugf("Created POST transition REST method %s", transitionURI)
+	if transitionURI != lowerTransitionURI {
+		r.Post(lowerTransitionURI, idempotencyHandler(restTransitionHandler(store)))
+		log.Debugf("Created POST transition REST method %s", lowerTransitionURI)
+	}
+}
+
+// sortedStates returns states of the store ordered by navOrder and name.
+func sortedStates(store config.Store) []processState {
+	res := make([]processState, 0, len(store.States))
+	for name, s := range store.States {
+		res = append(res, processState{Name: name, Label: s.Label, NavOrder: s.NavOrder})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].NavOrder != res[j].NavOrder {
+			return res[i].NavOrder < res[j].NavOrder
+		}
+
+		return res[i].Name < res[j].Name
+	})
+
+	return res
+}
+
+// restStatesHandler returns states of the process store with count of items available for the user in each state.
+// Items are counted with the same filters as in the list. Counts are not loaded with counts=false param.
+func restStatesHandler(store config.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest states]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest states]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		states := sortedStates(store)
+		params := r.URL.Query()
+		if s := params.Get("counts"); len(s) > 0 {
+			withCounts, err := strconv.ParseBool(s)
+			if err != nil {
+				errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("invalid counts param"))
+				return
+			}
+
+			if !withCounts {
+				apiResponse(w, r, http.StatusOK, states)
+				return
+			}
+		}
+
+		query, _, err := listQueryFromParams(store, params)
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := countStates(r.Context(), cred, store.Store, query, states); err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		apiResponse(w, r, http.StatusOK, states)
+	}
+}
+
+// countStates sets count of items in each state with bounded concurrency.
+func countStates(ctx context.Context, cred credentials, storeName string, query map[string]interface{}, states []processState) error {
+	var tokenInfo map[string]interface{}
+	if cred.claims != nil {
+		tokenInfo = cred.claims.toMap()
+	}
+
+	sem := make(chan struct{}, stateCountsParallel)
+	errs := make([]error, len(states))
+	var wg sync.WaitGroup
+	for i := range states {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			t := taskq.Task{
+				Type:   taskq.DbFind,
+				UserID: cred.userID,
+				Store:  storeName,
+				Arguments: map[string]interface{}{
+					"query": map[string]interface{}{
+						"query": mergeQueries(query, map[string]interface{}{stateProp: states[i].Name}),
+						"take":  1,
+						"props": []string{"_id"},
+					},
+				},
+			}
+			if tokenInfo != nil {
+				t.Arguments["tokenInfo"] = tokenInfo
+			}
+
+			res, err := pushTask(ctx, &t)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			result, _ := res.(map[string]interface{})
+			count, _ := result["count"].(float64)
+			n := int(count)
+			states[i].Count = &n
+		}(i)
+	}
+
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restTransitionHandler moves the item to the state from the request body, e.g. {"state": "done", "data": {...}}.
+// Optional data is passed to the workers together with the target state. Workers publish the state change
+// to the store subscribers, so every subscriber gets the event filtered by its permissions.
+func restTransitionHandler(store config.Store) http.HandlerFunc {
+	storeName := store.Store
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest transition]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest transition]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		var req transitionRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if _, ok := store.States[req.State]; !ok {
+			errorResponse(w, r, 0, newAPIError(http.StatusUnprocessableEntity, "validation_failed", "transition validation failed",
+				fieldError{"state", validationCodeOptions, fmt.Sprintf("unknown state %q", req.State)}))
+			return
+		}
+
+		var tokenInfo map[string]interface{}
+		if cred.claims != nil {
+			tokenInfo = cred.claims.toMap()
+		}
+
+		id := chi.URLParam(r, "id")
+		t := taskq.Task{
+			Type:      taskq.DbGet,
+			UserID:    cred.userID,
+			Store:     storeName,
+			Arguments: map[string]interface{}{"_id": id},
+		}
+		if tokenInfo != nil {
+			t.Arguments["tokenInfo"] = tokenInfo
+		}
+
+		res, err := pushTask(r.Context(), &t)
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		item, _ := res.(map[string]interface{})
+		from, _ := item[stateProp].(string)
+
+		t = taskq.Task{
+			Type:   taskDbTransition,
+			UserID: cred.userID,
+			Store:  storeName,
+			Arguments: map[string]interface{}{
+				"_id":   id,
+				"from":  from,
+				"state": req.State,
+				"data":  req.Data,
+			},
+		}
+		if tokenInfo != nil {
+			t.Arguments["tokenInfo"] = tokenInfo
+		}
+
+		if res, err = pushTask(r.Context(), &t); err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		apiResponse(w, r, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,32 @@
+package internet
+
+import (
+	"testing"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+func TestSortedStates(t *testing.T) {
+	store := config.Store{States: map[string]config.State{
+		"done":    {Label: "Done", NavOrder: 2},
+		"new":     {Label: "New", NavOrder: 0},
+		"review":  {Label: "Review", NavOrder: 1},
+		"backlog": {Label: "Backlog", NavOrder: 0},
+	}}
+
+	states := sortedStates(store)
+	expected := []string{"backlog", "new", "review", "done"}
+	if len(states) != len(expected) {
+		t.Fatalf("got %d states, expected: %d", len(states), len(expected))
+	}
+
+	for i, name := range expected {
+		if states[i].Name != name {
+			t.Errorf("state #%d is %q, expected: %q", i, states[i].Name, name)
+		}
+	}
+
+	if states[3].Label != "Done" || states[3].Count != nil {
+		t.Errorf("unexpected state %+v", states[3])
+	}
+}
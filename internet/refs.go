@@ -0,0 +1,171 @@
+package internet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/getblank/blank-router/berrors"
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+// restPushHandler appends request body to the list prop of the item, the same way as WAMP push command.
+func restPushHandler(storeName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest push]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest push]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		var data interface{}
+		if err := decodeRequestBody(w, r, &data); err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		t := taskq.Task{
+			Type:   taskq.DbPush,
+			UserID: cred.userID,
+			Store:  storeName,
+			Arguments: map[string]interface{}{
+				"_id":  chi.URLParam(r, "id"),
+				"prop": chi.URLParam(r, "prop"),
+				"data": data,
+			},
+		}
+		if cred.claims != nil {
+			t.Arguments["tokenInfo"] = cred.claims.toMap()
+		}
+
+		res, err := pushTask(r.Context(), &t)
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		apiResponse(w, r, http.StatusOK, res)
+	}
+}
+
+// restRefsHandler loads items referenced by the ref prop of the item. With selected=true only referenced items
+// are returned, otherwise all items of the referenced store available for selection.
+// Filters, orderBy, skip and take params are the same as in the list of the referenced store.
+func restRefsHandler(store config.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest refs]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest refs]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		propName := chi.URLParam(r, "prop")
+		prop, ok := store.Props[propName]
+		if !ok || len(prop.Store) == 0 {
+			errorResponse(w, r, 0, newAPIError(http.StatusNotFound, "not_found", fmt.Sprintf("ref prop %q not found", propName)))
+			return
+		}
+
+		refStore, ok := getStoreConfig(prop.Store)
+		if !ok {
+			refStore = config.Store{Store: prop.Store}
+		}
+
+		// response items are the items of the referenced store
+		r = withStore(r, refStore.Store)
+
+		params := r.URL.Query()
+		query, orderBy, err := listQueryFromParams(refStore, params)
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if len(orderBy) == 0 {
+			orderBy = defaultOrderBy(refStore)
+		}
+
+		take, err := parsePageSize(params.Get("take"), getStoreSettings(refStore.Store))
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var skip int
+		if s := params.Get("skip"); len(s) > 0 {
+			if skip, err = strconv.Atoi(s); err != nil || skip < 0 {
+				errorResponse(w, r, http.StatusBadRequest, errors.New("invalid skip param"))
+				return
+			}
+		}
+
+		var selected bool
+		if s := params.Get("selected"); len(s) > 0 {
+			if selected, err = strconv.ParseBool(s); err != nil {
+				errorResponse(w, r, http.StatusBadRequest, errors.New("invalid selected param"))
+				return
+			}
+		}
+
+		t := taskq.Task{
+			Type:   taskq.DbLoadRefs,
+			UserID: cred.userID,
+			Store:  store.Store,
+			Arguments: map[string]interface{}{
+				"_id":      chi.URLParam(r, "id"),
+				"prop":     propName,
+				"selected": selected,
+				"query":    map[string]interface{}{"query": query, "skip": skip, "take": take, "orderBy": orderBy},
+			},
+		}
+		if cred.claims != nil {
+			t.Arguments["tokenInfo"] = cred.claims.toMap()
+		}
+
+		res, err := pushTask(r.Context(), &t)
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		result, ok := res.(map[string]interface{})
+		if !ok {
+			errorResponse(w, r, http.StatusInternalServerError, berrors.ErrError)
+			return
+		}
+
+		items, _ := result["items"].([]interface{})
+		page := listPage{Items: items, Take: take}
+		if page.Items == nil {
+			page.Items = []interface{}{}
+		}
+
+		if count, ok := result["count"].(float64); ok {
+			n := int(count)
+			page.Count = &n
+			w.Header().Set(headerTotalCount, strconv.Itoa(n))
+		}
+
+		apiResponse(w, r, http.StatusOK, page)
+	}
+}
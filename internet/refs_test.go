@@ -0,0 +1,135 @@
+package internet
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+func withTestCred(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), credKey, credentials{userID: "u1"})))
+	})
+}
+
+func TestRestInsertHandler(t *testing.T) {
+	rt := chi.NewRouter()
+	rt.With(withTestCred).Post("/orders/_insert", restPostDocumentHandler("refsTestOrders", true))
+
+	testData := []struct {
+		err    string
+		status int
+	}{
+		{"", http.StatusCreated},
+		{"E11000 duplicate key error collection: orders index: _id_ dup key", http.StatusConflict},
+	}
+
+	for _, v := range testData {
+		served := serveTasks(1, func(task *taskq.Task) (interface{}, string) {
+			if v.err != "" {
+				return nil, v.err
+			}
+
+			return task.Arguments["item"], ""
+		})
+
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest("POST", "/orders/_insert", strings.NewReader(`{"_id":"1"}`)))
+		if w.Code != v.status {
+			t.Errorf("expected status %d, got %d: %s", v.status, w.Code, w.Body.String())
+		}
+
+		// the insert task is the only task, existence of the item is checked by the worker
+		task := <-served
+		if task.Type != taskq.DbInsert || task.Store != "refsTestOrders" {
+			t.Errorf("unexpected task %s for store %s", task.Type, task.Store)
+		}
+	}
+}
+
+func TestRestPushHandler(t *testing.T) {
+	rt := chi.NewRouter()
+	rt.With(withTestCred).Post("/orders/{id}/{prop}/_push", restPushHandler("refsTestOrders"))
+
+	served := serveTasks(1, func(task *taskq.Task) (interface{}, string) {
+		return map[string]interface{}{"_id": task.Arguments["_id"], "tags": []interface{}{task.Arguments["data"]}}, ""
+	})
+
+	r := httptest.NewRequest("POST", "/orders/1/tags/_push", strings.NewReader(`"new"`))
+	r.Header.Set("Accept", mediaTypeMsgPack)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	task := <-served
+	if task.Type != taskq.DbPush || task.Arguments["_id"] != "1" || task.Arguments["prop"] != "tags" || task.Arguments["data"] != "new" {
+		t.Fatalf("unexpected task %s with args %v", task.Type, task.Arguments)
+	}
+
+	var res map[string]interface{}
+	if err := msgpack.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	if tags, _ := res["tags"].([]interface{}); len(tags) != 1 || tags[0] != "new" {
+		t.Fatalf("unexpected response %v", res)
+	}
+}
+
+func TestRestRefsHandler(t *testing.T) {
+	store := config.Store{
+		Store: "refsTestOrders",
+		Props: map[string]config.Prop{"customer": {Type: config.PropRef, Store: "refsTestCustomers"}},
+	}
+
+	rt := chi.NewRouter()
+	rt.With(withTestCred).Get("/orders/{id}/{prop}/_refs", restRefsHandler(store))
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/orders/1/unknown/_refs", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404 for unknown prop, got %d", w.Code)
+	}
+
+	served := serveTasks(1, func(task *taskq.Task) (interface{}, string) {
+		return map[string]interface{}{"items": []interface{}{map[string]interface{}{"_id": "c1"}}, "count": float64(1)}, ""
+	})
+
+	r := httptest.NewRequest("GET", "/orders/1/customer/_refs?selected=true&take=10", nil)
+	r.Header.Set("Accept", mediaTypeMsgPack)
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	task := <-served
+	query, _ := task.Arguments["query"].(map[string]interface{})
+	if task.Type != taskq.DbLoadRefs || task.Arguments["prop"] != "customer" || task.Arguments["selected"] != true || query["take"] != 10 {
+		t.Fatalf("unexpected task %s with args %v", task.Type, task.Arguments)
+	}
+
+	if n := w.Header().Get(headerTotalCount); n != "1" {
+		t.Fatalf("expected total count 1, got %q", n)
+	}
+
+	var res map[string]interface{}
+	if err := msgpack.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	if items, _ := res["items"].([]interface{}); len(items) != 1 {
+		t.Fatalf("unexpected response %v", res)
+	}
+}
@@ -0,0 +1,380 @@
+package internet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"github.com/getblank/blank-router/berrors"
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+type linkRequest struct {
+	IDs []interface{} `json:"ids"`
+}
+
+// createRelationsRESTAPI creates routes for the ref, refList and virtualRefList props of the store:
+//
+//	GET    /{store}/{id}/{prop}  related items of the referenced store
+//	POST   /{store}/{id}/{prop}  links items to the refList prop, body: {"ids": [...]}
+//	DELETE /{store}/{id}/{prop}  unlinks items from the refList prop, body: {"ids": [...]} or ids=1,2 param
+func createRelationsRESTAPI(r chi.Router, store config.Store, itemURI, lowerItemURI string) {
+	actions := map[string]bool{}
+	for _, a := range store.Actions {
+		actions[strings.ToLower(a.ID)] = true
+	}
+
+	for name, p := range store.Props {
+		switch p.Type {
+		case config.PropRef, config.PropRefList, config.PropVirtualRefList:
+		default:
+			continue
+		}
+
+		uri := itemURI + "/" + name
+		lowerURI := lowerItemURI + "/" + strings.ToLower(name)
+		r.Get(uri, restRelationHandler(store, name))
+		log.Debugf("Created GET relation REST method %s", uri)
+		if uri != lowerURI {
+			r.Get(lowerURI, restRelationHandler(store, name))
+			log.Debugf("Created GET relation REST method %s", lowerURI)
+		}
+
+		if p.Type != config.PropRefList {
+			continue
+		}
+
+		if actions[strings.ToLower(name)] {
+			log.Warnf("Action %q of store %q has the same name as refList prop, link REST methods are not created", name, store.Store)
+			continue
+		}
+
+		r.Post(uri, idempotencyHandler(restLinkHandler(store, name, true)))
+		r.Delete(uri, restLinkHandler(store, name, false))
+		log.Debugf("Created POST and DELETE link REST methods %s", uri)
+		if uri != lowerURI {
+			r.Post(lowerURI, idempotencyHandler(restLinkHandler(store, name, true)))
+			r.Delete(lowerURI, restLinkHandler(store, name, false))
+			log.Debugf("Created POST and DELETE link REST methods %s", lowerURI)
+		}
+	}
+}
+
+// relationQuery returns query of the items related to the item by the prop. Nil query means there are no related items.
+func relationQuery(p config.Prop, propName string, item map[string]interface{}) (map[string]interface{}, error) {
+	switch p.Type {
+	case config.PropRef:
+		if v := item[propName]; v != nil {
+			return map[string]interface{}{"_id": v}, nil
+		}
+	case config.PropRefList:
+		if ids, _ := item[propName].([]interface{}); len(ids) > 0 {
+			return map[string]interface{}{"_id": map[string]interface{}{"$in": ids}}, nil
+		}
+	case config.PropVirtualRefList:
+		if len(p.ForeignKey) == 0 {
+			return nil, newAPIError(http.StatusNotImplemented, "not_implemented", fmt.Sprintf("virtualRefList prop %q without foreignKey is not supported", propName))
+		}
+
+		return map[string]interface{}{p.ForeignKey: item["_id"]}, nil
+	}
+
+	return nil, nil
+}
+
+func loadItem(ctx context.Context, cred credentials, storeName string, id interface{}) (map[string]interface{}, error) {
+	t := taskq.Task{
+		Type:      taskq.DbGet,
+		UserID:    cred.userID,
+		Store:     storeName,
+		Arguments: map[string]interface{}{"_id": id},
+	}
+	if cred.claims != nil {
+		t.Arguments["tokenInfo"] = cred.claims.toMap()
+	}
+
+	res, err := pushTask(ctx, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	item, ok := res.(map[string]interface{})
+	if !ok {
+		return nil, berrors.ErrError
+	}
+
+	return item, nil
+}
+
+// restRelationHandler returns page of the items related to the item by the prop.
+// Filters, orderBy, skip and take params are the same as in the list of the referenced store.
+func restRelationHandler(store config.Store, propName string) http.HandlerFunc {
+	prop := store.Props[propName]
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest relation]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest relation]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		refStore, ok := getStoreConfig(prop.Store)
+		if !ok {
+			refStore = config.Store{Store: prop.Store}
+		}
+
+		// response items are the items of the referenced store
+		r = withStore(r, refStore.Store)
+
+		params := r.URL.Query()
+		query, orderBy, err := listQueryFromParams(refStore, params)
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if len(orderBy) == 0 {
+			orderBy = defaultOrderBy(refStore)
+		}
+
+		take, err := parsePageSize(params.Get("take"), getStoreSettings(refStore.Store))
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var skip int
+		if s := params.Get("skip"); len(s) > 0 {
+			if skip, err = strconv.Atoi(s); err != nil || skip < 0 {
+				errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("invalid skip param"))
+				return
+			}
+		}
+
+		item, err := loadItem(r.Context(), cred, store.Store, chi.URLParam(r, "id"))
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		relQuery, err := relationQuery(prop, propName, item)
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		page := listPage{Items: []interface{}{}, Take: take}
+		if relQuery == nil {
+			n := 0
+			page.Count = &n
+			w.Header().Set(headerTotalCount, "0")
+			apiResponse(w, r, http.StatusOK, page)
+			return
+		}
+
+		// related items are loaded with the permissions of the user in the referenced store
+		t := taskq.Task{
+			Type:   taskq.DbFind,
+			UserID: cred.userID,
+			Store:  refStore.Store,
+			Arguments: map[string]interface{}{
+				"query": map[string]interface{}{"query": mergeQueries(relQuery, query), "skip": skip, "take": take, "orderBy": orderBy},
+			},
+		}
+		if cred.claims != nil {
+			t.Arguments["tokenInfo"] = cred.claims.toMap()
+		}
+
+		res, err := pushTask(r.Context(), &t)
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		result, ok := res.(map[string]interface{})
+		if !ok {
+			errorResponse(w, r, http.StatusInternalServerError, berrors.ErrError)
+			return
+		}
+
+		if items, _ := result["items"].([]interface{}); items != nil {
+			page.Items = items
+		}
+
+		if count, ok := result["count"].(float64); ok {
+			n := int(count)
+			page.Count = &n
+			w.Header().Set(headerTotalCount, strconv.Itoa(n))
+		}
+
+		apiResponse(w, r, http.StatusOK, page)
+	}
+}
+
+// restLinkHandler adds ids to the refList prop of the item with DbPush tasks or removes them with DbSet task
+// and returns the new list.
+func restLinkHandler(store config.Store, propName string, link bool) http.HandlerFunc {
+	prop := store.Props[propName]
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest link]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest link]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		ids, err := linkIDs(w, r, prop, propName)
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		id := chi.URLParam(r, "id")
+		item, err := loadItem(r.Context(), cred, store.Store, id)
+		if err != nil {
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		// ids are pushed to the list by the workers, so concurrent links don't overwrite each other.
+		// Workers can't pull values from the list, so unlink sets the loaded list without the ids.
+		current, _ := item[propName].([]interface{})
+		var tasks []taskq.Task
+		if link {
+			for _, linkID := range missingIDs(current, ids) {
+				tasks = append(tasks, taskq.Task{
+					Type:      taskq.DbPush,
+					Arguments: map[string]interface{}{"_id": id, "prop": propName, "data": linkID},
+				})
+			}
+		} else if rest := withoutIDs(current, ids); len(rest) < len(current) {
+			tasks = append(tasks, taskq.Task{
+				Type:      taskq.DbSet,
+				Arguments: map[string]interface{}{"item": map[string]interface{}{"_id": item["_id"], propName: rest}},
+			})
+		}
+
+		for i := range tasks {
+			t := &tasks[i]
+			t.UserID = cred.userID
+			t.Store = store.Store
+			if cred.claims != nil {
+				t.Arguments["tokenInfo"] = cred.claims.toMap()
+			}
+
+			if _, err := pushTask(r.Context(), t); err != nil {
+				errorResponse(w, r, 0, err)
+				return
+			}
+		}
+
+		if len(tasks) > 0 {
+			if item, err = loadItem(r.Context(), cred, store.Store, id); err != nil {
+				errorResponse(w, r, 0, err)
+				return
+			}
+		}
+
+		updated, _ := item[propName].([]interface{})
+		if updated == nil {
+			updated = []interface{}{}
+		}
+
+		apiResponse(w, r, http.StatusOK, updated)
+	}
+}
+
+// linkIDs reads ids from the request body or from the comma separated ids param.
+// Ids are converted to int if _id of the referenced store is int.
+func linkIDs(w http.ResponseWriter, r *http.Request, p config.Prop, propName string) ([]interface{}, error) {
+	var ids []interface{}
+	if raw := r.URL.Query().Get("ids"); len(raw) > 0 {
+		for _, s := range strings.Split(raw, ",") {
+			v, err := coerceFilterValue(p, propName, strings.TrimSpace(s))
+			if err != nil {
+				return nil, err
+			}
+
+			ids = append(ids, v)
+		}
+	} else {
+		var req linkRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			return nil, err
+		}
+
+		intIDs := false
+		if refStore, ok := getStoreConfig(p.Store); ok && refStore.Props["_id"].Type == config.PropInt {
+			intIDs = true
+		}
+
+		for _, v := range req.IDs {
+			if n, ok := numberValue(v); ok && intIDs {
+				v = int(n)
+			}
+
+			ids = append(ids, v)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("ids are required")
+	}
+
+	return ids, nil
+}
+
+// missingIDs returns ids which are not in the list yet.
+func missingIDs(current, ids []interface{}) []interface{} {
+	var res []interface{}
+	for _, id := range ids {
+		if indexOfID(current, id) < 0 && indexOfID(res, id) < 0 {
+			res = append(res, id)
+		}
+	}
+
+	return res
+}
+
+// withoutIDs returns the list without ids.
+func withoutIDs(list, ids []interface{}) []interface{} {
+	res := []interface{}{}
+	for _, v := range list {
+		if indexOfID(ids, v) < 0 {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// indexOfID compares ids by their string representation, because numbers can be decoded as int or float64.
+func indexOfID(list []interface{}, id interface{}) int {
+	s := fmt.Sprint(id)
+	for i, v := range list {
+		if fmt.Sprint(v) == s {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,204 @@
+package internet
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+var relationsTestStore = config.Store{
+	Store: "relTestOrders",
+	Props: map[string]config.Prop{
+		"customer": {Type: config.PropRef, Store: "relTestCustomers"},
+		"tags":     {Type: config.PropRefList, Store: "relTestTags"},
+	},
+}
+
+// relationsTestWorker handles tasks with the order "1" and tags "a", "b", "c" as the worker does.
+type relationsTestWorker struct {
+	order map[string]interface{}
+	tags  []map[string]interface{}
+}
+
+func newRelationsTestWorker(tags ...interface{}) *relationsTestWorker {
+	return &relationsTestWorker{
+		order: map[string]interface{}{"_id": "1", "tags": tags},
+		tags:  []map[string]interface{}{{"_id": "a"}, {"_id": "b"}, {"_id": "c"}},
+	}
+}
+
+func (rw *relationsTestWorker) serve(n int) <-chan *taskq.Task {
+	return serveTasks(n, func(t *taskq.Task) (interface{}, string) {
+		switch t.Type {
+		case taskq.DbGet:
+			tags, _ := rw.order["tags"].([]interface{})
+			return map[string]interface{}{"_id": rw.order["_id"], "tags": append([]interface{}{}, tags...)}, ""
+		case taskq.DbPush:
+			tags, _ := rw.order["tags"].([]interface{})
+			rw.order["tags"] = append(tags, t.Arguments["data"])
+			return rw.order, ""
+		case taskq.DbSet:
+			item, _ := t.Arguments["item"].(map[string]interface{})
+			for k, v := range item {
+				rw.order[k] = v
+			}
+
+			return rw.order, ""
+		case taskq.DbFind:
+			return fakeFind(rw.tags, t), ""
+		}
+
+		return nil, "unexpected task " + t.Type
+	})
+}
+
+func decodeMsgPackResponse(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if err := msgpack.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestRelationHandler(t *testing.T) {
+	rt := chi.NewRouter()
+	rt.With(withTestCred).Get("/orders/{id}/tags", restRelationHandler(relationsTestStore, "tags"))
+	rt.With(withTestCred).Get("/orders/{id}/customer", restRelationHandler(relationsTestStore, "customer"))
+
+	served := newRelationsTestWorker("c", "a").serve(2)
+	r := httptest.NewRequest("GET", "/orders/1/tags?orderBy=-_id", nil)
+	r.Header.Set("Accept", mediaTypeMsgPack)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+
+	var page map[string]interface{}
+	decodeMsgPackResponse(t, w, &page)
+	if ids := pageIDs(page); !reflect.DeepEqual(ids, []string{"c", "a"}) {
+		t.Fatalf("expected related tags [c a], got %v", ids)
+	}
+
+	if n := w.Header().Get(headerTotalCount); n != "2" {
+		t.Fatalf("expected total count 2, got %q", n)
+	}
+
+	<-served
+	if task := <-served; task.Type != taskq.DbFind || task.Store != "relTestTags" {
+		t.Fatalf("related items are loaded with %s task from store %s", task.Type, task.Store)
+	}
+
+	// order without customer has no related items, so only the order is loaded
+	served = newRelationsTestWorker().serve(1)
+	r = httptest.NewRequest("GET", "/orders/1/customer", nil)
+	r.Header.Set("Accept", mediaTypeMsgPack)
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+
+	page = nil
+	decodeMsgPackResponse(t, w, &page)
+	if items, _ := page["items"].([]interface{}); len(items) != 0 || w.Header().Get(headerTotalCount) != "0" {
+		t.Fatalf("expected empty page, got %v", page)
+	}
+
+	<-served
+}
+
+func TestRestLinkHandler(t *testing.T) {
+	rt := chi.NewRouter()
+	rt.With(withTestCred).Post("/orders/{id}/tags", restLinkHandler(relationsTestStore, "tags", true))
+	rt.With(withTestCred).Delete("/orders/{id}/tags", restLinkHandler(relationsTestStore, "tags", false))
+
+	testData := []struct {
+		method   string
+		url      string
+		body     string
+		tasks    []string
+		expected []interface{}
+	}{
+		{"POST", "/orders/1/tags", `{"ids":["b","c"]}`, []string{taskq.DbGet, taskq.DbPush, taskq.DbGet}, []interface{}{"a", "b", "c"}},
+		{"POST", "/orders/1/tags", `{"ids":["a","b"]}`, []string{taskq.DbGet}, []interface{}{"a", "b"}},
+		{"DELETE", "/orders/1/tags?ids=a,c", "", []string{taskq.DbGet, taskq.DbSet, taskq.DbGet}, []interface{}{"b"}},
+		{"DELETE", "/orders/1/tags", `{"ids":["c"]}`, []string{taskq.DbGet}, []interface{}{"a", "b"}},
+	}
+
+	for i, v := range testData {
+		served := newRelationsTestWorker("a", "b").serve(len(v.tasks))
+		r := httptest.NewRequest(v.method, v.url, strings.NewReader(v.body))
+		r.Header.Set("Accept", mediaTypeMsgPack)
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, r)
+
+		var res []interface{}
+		decodeMsgPackResponse(t, w, &res)
+		if !reflect.DeepEqual(res, v.expected) {
+			t.Errorf("#%d: expected list %v, got %v", i, v.expected, res)
+		}
+
+		var tasks []string
+		for task := range served {
+			tasks = append(tasks, task.Type)
+		}
+
+		if !reflect.DeepEqual(tasks, v.tasks) {
+			t.Errorf("#%d: expected tasks %v, got %v", i, v.tasks, tasks)
+		}
+	}
+}
+
+func TestRelationQuery(t *testing.T) {
+	item := map[string]interface{}{"_id": "1", "owner": "u1", "tags": []interface{}{"a", "b"}}
+	testData := []struct {
+		prop     config.Prop
+		name     string
+		expected map[string]interface{}
+	}{
+		{config.Prop{Type: config.PropRef}, "owner", map[string]interface{}{"_id": "u1"}},
+		{config.Prop{Type: config.PropRef}, "manager", nil},
+		{config.Prop{Type: config.PropRefList}, "tags", map[string]interface{}{"_id": map[string]interface{}{"$in": []interface{}{"a", "b"}}}},
+		{config.Prop{Type: config.PropRefList}, "empty", nil},
+		{config.Prop{Type: config.PropVirtualRefList, ForeignKey: "parentId"}, "children", map[string]interface{}{"parentId": "1"}},
+	}
+
+	for i, v := range testData {
+		res, err := relationQuery(v.prop, v.name, item)
+		if err != nil {
+			t.Fatalf("#%d: unexpected error %v", i, err)
+		}
+
+		if !reflect.DeepEqual(res, v.expected) {
+			t.Errorf("#%d: relationQuery => %v, expected: %v", i, res, v.expected)
+		}
+	}
+
+	if _, err := relationQuery(config.Prop{Type: config.PropVirtualRefList}, "byQuery", item); err == nil {
+		t.Error("virtualRefList without foreignKey was accepted")
+	}
+}
+
+func TestMissingIDs(t *testing.T) {
+	current := []interface{}{"a", 1}
+
+	if res := missingIDs(current, []interface{}{"b", "a", 1.0, "b"}); !reflect.DeepEqual(res, []interface{}{"b"}) {
+		t.Errorf("missingIDs => %v", res)
+	}
+
+	if res := missingIDs(current, []interface{}{1.0}); res != nil {
+		t.Errorf("missingIDs => %v", res)
+	}
+}
+
+func TestWithoutIDs(t *testing.T) {
+	if res := withoutIDs([]interface{}{"a", 1, "b"}, []interface{}{1.0, "b", "c"}); !reflect.DeepEqual(res, []interface{}{"a"}) {
+		t.Errorf("withoutIDs => %v", res)
+	}
+}
@@ -18,12 +18,14 @@ var (
 	applicationXML           = "application/xml; charset=utf-8"
 )
 
-func errorResponse(w http.ResponseWriter, status int, err error) {
-	jsonResponseWithStatus(w, status, err.Error())
+// errorResponse writes err as problem details. Status is used if err doesn't provide its own,
+// pass 0 to map worker errors by their text.
+func errorResponse(w http.ResponseWriter, r *http.Request, status int, err error) {
+	problemResponse(w, r, toAPIError(status, err))
 }
 
-func invalidArguments(w http.ResponseWriter) {
-	errorResponse(w, http.StatusBadRequest, berrors.ErrInvalidArguments)
+func invalidArguments(w http.ResponseWriter, r *http.Request) {
+	errorResponse(w, r, http.StatusBadRequest, berrors.ErrInvalidArguments)
 }
 
 func jsonResponse(w http.ResponseWriter, data interface{}) {
@@ -33,7 +35,7 @@ func jsonResponse(w http.ResponseWriter, data interface{}) {
 func jsonResponseWithStatus(w http.ResponseWriter, status int, data interface{}) {
 	encoded, err := json.Marshal(data)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err)
+		errorResponse(w, nil, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -26,27 +26,77 @@ func createRESTAPI(httpEnabledStores []config.Store) {
 }
 
 func createRESTAPIForStore(store config.Store) {
+	if store.Type == config.ObjSingle {
+		createRESTAPIForSingleStore(store)
+		return
+	}
+
 	log.Debugf("Creating REST API for store %q", store.Store)
 	baseURI := apiV1baseURI + store.Store
 	lowerBaseURI := strings.ToLower(baseURI)
 
-	gr := r.With(allowAnyOriginMiddleware, jwtAuthMiddleware(true))
-	gr.Get(baseURI, restGetAllDocumentsHandler(store.Store))
+	gr := r.With(allowAnyOriginMiddleware, jwtAuthMiddleware(true), storeMiddleware(store.Store))
+	gr.Get(baseURI, restGetAllDocumentsHandler(store))
 	log.Debugf("Created GET all REST method %s", baseURI)
 	if baseURI != lowerBaseURI {
-		gr.Get(lowerBaseURI, restGetAllDocumentsHandler(store.Store))
+		gr.Get(lowerBaseURI, restGetAllDocumentsHandler(store))
 		log.Debugf("Created GET all REST method %s", lowerBaseURI)
 	}
 
-	r := r.With(allowAnyOriginMiddleware, jwtAuthMiddleware(false))
-	r.Post(baseURI, restPostDocumentHandler(store.Store))
+	exportURI := baseURI + "/_export"
+	lowerExportURI := lowerBaseURI + "/_export"
+	gr.Get(exportURI, restExportHandler(store))
+	log.Debugf("Created GET export REST method %s", exportURI)
+	if exportURI != lowerExportURI {
+		gr.Get(lowerExportURI, restExportHandler(store))
+		log.Debugf("Created GET export REST method %s", lowerExportURI)
+	}
+
+	r := r.With(allowAnyOriginMiddleware, jwtAuthMiddleware(false), storeMiddleware(store.Store))
+	r.Post(baseURI, idempotencyHandler(restPostDocumentHandler(store.Store, false)))
 	log.Debugf("Created POST REST method %s", baseURI)
 
 	if baseURI != lowerBaseURI {
-		r.Post(lowerBaseURI, restPostDocumentHandler(store.Store))
+		r.Post(lowerBaseURI, idempotencyHandler(restPostDocumentHandler(store.Store, false)))
 		log.Debugf("Created POST REST method %s", lowerBaseURI)
 	}
 
+	insertURI := baseURI + "/_insert"
+	lowerInsertURI := lowerBaseURI + "/_insert"
+	r.Post(insertURI, idempotencyHandler(restPostDocumentHandler(store.Store, true)))
+	log.Debugf("Created POST insert REST method %s", insertURI)
+	if insertURI != lowerInsertURI {
+		r.Post(lowerInsertURI, idempotencyHandler(restPostDocumentHandler(store.Store, true)))
+		log.Debugf("Created POST insert REST method %s", lowerInsertURI)
+	}
+
+	bulkURI := baseURI + "/_bulk"
+	lowerBulkURI := lowerBaseURI + "/_bulk"
+	r.Post(bulkURI, idempotencyHandler(restBulkHandler(store.Store)))
+	log.Debugf("Created POST bulk REST method %s", bulkURI)
+	if bulkURI != lowerBulkURI {
+		r.Post(lowerBulkURI, idempotencyHandler(restBulkHandler(store.Store)))
+		log.Debugf("Created POST bulk REST method %s", lowerBulkURI)
+	}
+
+	changesURI := baseURI + "/_changes"
+	lowerChangesURI := lowerBaseURI + "/_changes"
+	r.Get(changesURI, restChangesHandler(store))
+	log.Debugf("Created GET changes REST method %s", changesURI)
+	if changesURI != lowerChangesURI {
+		r.Get(lowerChangesURI, restChangesHandler(store))
+		log.Debugf("Created GET changes REST method %s", lowerChangesURI)
+	}
+
+	importURI := baseURI + "/_import"
+	lowerImportURI := lowerBaseURI + "/_import"
+	r.Post(importURI, restImportHandler(store))
+	log.Debugf("Created POST import REST method %s", importURI)
+	if importURI != lowerImportURI {
+		r.Post(lowerImportURI, restImportHandler(store))
+		log.Debugf("Created POST import REST method %s", lowerImportURI)
+	}
+
 	itemURI := baseURI + "/{id}"
 	lowerItemURI := lowerBaseURI + "/{id}"
 	r.Get(itemURI, restGetDocumentHandler(store.Store))
@@ -56,10 +106,10 @@ func createRESTAPIForStore(store config.Store) {
 		log.Debugf("Created GET REST method %s", lowerItemURI)
 	}
 
-	r.Put(itemURI, restPutDocumentHandler(store.Store))
+	r.Put(itemURI, idempotencyHandler(restPutDocumentHandler(store.Store)))
 	log.Debugf("Created PUT REST method %s", itemURI)
 	if itemURI != lowerItemURI {
-		r.Put(lowerItemURI, restPutDocumentHandler(store.Store))
+		r.Put(lowerItemURI, idempotencyHandler(restPutDocumentHandler(store.Store)))
 		log.Debugf("Created PUT REST method %s", lowerItemURI)
 	}
 
@@ -70,13 +120,41 @@ func createRESTAPIForStore(store config.Store) {
 		log.Debugf("Created DELETE REST method %s", lowerItemURI)
 	}
 
+	pushURI := itemURI + "/{prop}/_push"
+	lowerPushURI := lowerItemURI + "/{prop}/_push"
+	r.Post(pushURI, idempotencyHandler(restPushHandler(store.Store)))
+	log.Debugf("Created POST push REST method %s", pushURI)
+	if pushURI != lowerPushURI {
+		r.Post(lowerPushURI, idempotencyHandler(restPushHandler(store.Store)))
+		log.Debugf("Created POST push REST method %s", lowerPushURI)
+	}
+
+	refsURI := itemURI + "/{prop}/_refs"
+	lowerRefsURI := lowerItemURI + "/{prop}/_refs"
+	r.Get(refsURI, restRefsHandler(store))
+	log.Debugf("Created GET refs REST method %s", refsURI)
+	if refsURI != lowerRefsURI {
+		r.Get(lowerRefsURI, restRefsHandler(store))
+		log.Debugf("Created GET refs REST method %s", lowerRefsURI)
+	}
+
+	createRelationsRESTAPI(r, store, itemURI, lowerItemURI)
+
+	if store.Type == config.ObjProcess {
+		createProcessRESTAPI(r, store, baseURI, lowerBaseURI)
+	}
+
+	if store.Type == config.ObjNotification {
+		createNotificationRESTAPI(r, store, baseURI, lowerBaseURI)
+	}
+
 	for _, a := range store.Actions {
 		actionURI := itemURI + "/" + a.ID
 		lowerActionURI := lowerItemURI + "/" + strings.ToLower(a.ID)
-		r.Post(actionURI, restActionHandler(store.Store, a.ID))
+		r.Post(actionURI, idempotencyHandler(restActionHandler(store.Store, a.ID)))
 		log.Debugf("Created POST action REST method %s", actionURI)
 		if actionURI != lowerActionURI {
-			r.Post(lowerActionURI, restActionHandler(store.Store, a.ID))
+			r.Post(lowerActionURI, idempotencyHandler(restActionHandler(store.Store, a.ID)))
 			log.Debugf("Created POST action REST method %s", lowerActionURI)
 		}
 	}
@@ -84,10 +162,10 @@ func createRESTAPIForStore(store config.Store) {
 	for _, a := range store.StoreActions {
 		actionURI := baseURI + "/" + a.ID
 		lowerActionURI := lowerBaseURI + "/" + strings.ToLower(a.ID)
-		r.Post(actionURI, restActionHandler(store.Store, a.ID))
+		r.Post(actionURI, idempotencyHandler(restActionHandler(store.Store, a.ID)))
 		log.Debugf("Created POST storeAction REST method %s", actionURI)
 		if actionURI != lowerActionURI {
-			r.Post(lowerActionURI, restActionHandler(store.Store, a.ID))
+			r.Post(lowerActionURI, idempotencyHandler(restActionHandler(store.Store, a.ID)))
 			log.Debugf("Created POST storeAction REST method %s", lowerActionURI)
 		}
 	}
@@ -103,18 +181,25 @@ func restActionHandler(storeName, actionID string) http.HandlerFunc {
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[rest action]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[rest action]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 		credTiming.End()
 
+		request, err := extractRequest(r, storeName)
+		if err != nil {
+			totalTiming.End()
+			errorResponse(w, r, 0, err)
+			return
+		}
+
 		taskTiming := newServerTiming(w, "task")
 		log.Debugf("REST ACTION: store: %s, actionID: %s. credentials extracted", storeName, actionID)
 		t := taskq.Task{
@@ -124,46 +209,35 @@ func restActionHandler(storeName, actionID string) http.HandlerFunc {
 			Arguments: map[string]interface{}{
 				"itemId":   chi.URLParam(r, "id"),
 				"actionId": actionID,
-				"request":  extractRequest(r),
+				"request":  request,
 			},
 		}
 		if cred.claims != nil {
 			t.Arguments["tokenInfo"] = cred.claims.toMap()
 		}
 
-		res, err := taskq.PushAndGetResult(&t, 0)
+		if async, preferred := isAsyncAction(r, storeName, actionID); async {
+			taskTiming.End()
+			totalTiming.End()
+			asyncActionResponse(w, r, cred, &t, preferred)
+			return
+		}
+
+		res, err := pushTask(r.Context(), &t)
 		taskTiming.End()
 		if err != nil {
-			errText := err.Error()
-			if strings.EqualFold(errText, "not found") {
-				jsonResponseWithStatus(w, http.StatusNotFound, errText)
-				return
-			}
-
-			if strings.EqualFold(errText, "unauthorized") {
-				jsonResponseWithStatus(w, http.StatusForbidden, errText)
-				return
-			}
-
-			fields := strings.SplitN(errText, " ", 2)
-			statusCode := http.StatusInternalServerError
-			if len(fields) > 1 {
-				if i, err := strconv.Atoi(fields[0]); err == nil {
-					statusCode = i
-					errText = fields[1]
-				}
-			}
-
+			removeTaskUploads(&t)
 			totalTiming.End()
-			jsonResponseWithStatus(w, statusCode, errText)
+			errorResponse(w, r, 0, err)
 			return
 		}
 
-		jsonResponse(w, res)
+		apiResponse(w, r, http.StatusOK, res)
 	}
 }
 
-func restGetAllDocumentsHandler(storeName string) http.HandlerFunc {
+func restGetAllDocumentsHandler(store config.Store) http.HandlerFunc {
+	storeName := store.Store
 	return func(w http.ResponseWriter, r *http.Request) {
 		totalTiming := newServerTiming(w, "total")
 
@@ -171,53 +245,81 @@ func restGetAllDocumentsHandler(storeName string) http.HandlerFunc {
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[rest get all]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[rest get all]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 		credTiming.End()
 
 		queryTiming := newServerTiming(w, "query")
-		var query map[string]interface{}
-		if q := r.URL.Query().Get("query"); len(q) > 0 {
-			err := json.Unmarshal([]byte(q), &query)
+		params := r.URL.Query()
+		query, orderBy, err := listQueryFromParams(store, params)
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		take, err := parsePageSize(params.Get("take"), getStoreSettings(storeName))
+		if err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		var cursor *pageCursor
+		if cs := params.Get("cursor"); len(cs) > 0 {
+			pc, err := decodeCursor(cs)
 			if err != nil {
-				errorResponse(w, http.StatusBadRequest, err)
+				errorResponse(w, r, http.StatusBadRequest, err)
 				return
 			}
-		}
 
-		findQuery := map[string]interface{}{"query": query, "skip": 0, "take": 10}
-		if s := r.URL.Query().Get("skip"); len(s) > 0 {
-			var skip int
-			skip, err := strconv.Atoi(s)
-			if err != nil {
-				errorResponse(w, http.StatusBadRequest, err)
+			if len(orderBy) > 0 && orderBy != pc.OrderBy {
+				errorResponse(w, r, http.StatusBadRequest, errors.New("cursor does not match orderBy param"))
 				return
 			}
 
-			findQuery["skip"] = skip
+			orderBy = pc.OrderBy
+			cursor = &pc
+		}
+
+		if len(orderBy) == 0 {
+			orderBy = defaultOrderBy(store)
 		}
 
-		if t := r.URL.Query().Get("take"); len(t) > 0 {
-			var take int
-			take, err := strconv.Atoi(t)
+		var skip int
+		if s := params.Get("skip"); len(s) > 0 && cursor == nil {
+			skip, err = strconv.Atoi(s)
 			if err != nil {
-				errorResponse(w, http.StatusBadRequest, err)
+				errorResponse(w, r, http.StatusBadRequest, err)
 				return
 			}
+		}
 
-			findQuery["take"] = take
+		taskOrderBy := stableOrderBy(orderBy)
+		if cursor != nil {
+			if cursor.Prev {
+				taskOrderBy = reverseOrderBy(taskOrderBy)
+			}
+
+			query = mergeQueries(query, cursorQuery(*cursor, taskOrderBy))
 		}
 
-		if orderBy := r.URL.Query().Get("orderBy"); len(orderBy) > 0 {
-			findQuery["orderBy"] = orderBy
+		// one extra item is requested to find out if there is the next page
+		findQuery := map[string]interface{}{"query": query, "skip": skip, "take": take + 1, "orderBy": taskOrderBy}
+		if fields := params.Get("fields"); len(fields) > 0 {
+			props, err := parseFieldsParam(store, fields, orderBy)
+			if err != nil {
+				errorResponse(w, r, http.StatusBadRequest, err)
+				return
+			}
+
+			findQuery["props"] = props
 		}
 		queryTiming.End()
 
@@ -234,25 +336,54 @@ func restGetAllDocumentsHandler(storeName string) http.HandlerFunc {
 			t.Arguments["tokenInfo"] = cred.claims.toMap()
 		}
 
-		res, err := taskq.PushAndGetResult(&t, 0)
+		res, err := pushTask(r.Context(), &t)
 		taskTiming.End()
 		if err != nil {
-			if strings.EqualFold(err.Error(), "not found") {
-				errorResponse(w, http.StatusNotFound, err)
-				return
+			errorResponse(w, r, 0, err)
+			return
+		}
+
+		result, ok := res.(map[string]interface{})
+		if !ok {
+			errorResponse(w, r, http.StatusInternalServerError, berrors.ErrError)
+			return
+		}
+
+		items, _ := result["items"].([]interface{})
+		hasMore := len(items) > take
+		if hasMore {
+			items = items[:take]
+		}
+
+		backward := cursor != nil && cursor.Prev
+		if backward {
+			for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+				items[i], items[j] = items[j], items[i]
+			}
+		}
+
+		page := listPage{Items: items, Take: take}
+		if len(items) > 0 {
+			if backward || hasMore {
+				page.NextCursor = cursorForItem(items[len(items)-1], orderBy, false)
 			}
 
-			if strings.EqualFold(err.Error(), "unauthorized") {
-				jsonResponseWithStatus(w, http.StatusForbidden, err.Error())
-				return
+			if (backward && hasMore) || (!backward && (cursor != nil || skip > 0)) {
+				page.PrevCursor = cursorForItem(items[0], orderBy, true)
 			}
+		}
 
-			errorResponse(w, http.StatusSeeOther, err)
-			return
+		if withCount, _ := strconv.ParseBool(params.Get("count")); withCount {
+			if count, ok := result["count"].(float64); ok {
+				n := int(count)
+				page.Count = &n
+				w.Header().Set(headerTotalCount, strconv.Itoa(n))
+			}
 		}
 
+		setLinkHeader(w, r, page)
 		totalTiming.End()
-		jsonResponse(w, res)
+		apiResponse(w, r, http.StatusOK, page)
 	}
 }
 
@@ -264,21 +395,21 @@ func restGetDocumentHandler(storeName string) http.HandlerFunc {
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[rest get]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[rest get]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 		credTiming.End()
 
 		id := chi.URLParam(r, "id")
 		if len(id) == 0 {
-			jsonResponseWithStatus(w, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+			errorResponse(w, r, http.StatusBadRequest, nil)
 			return
 		}
 
@@ -294,7 +425,7 @@ func restGetDocumentHandler(storeName string) http.HandlerFunc {
 		if ver := r.URL.Query().Get("__v"); len(ver) > 0 {
 			v, err := strconv.Atoi(ver)
 			if err != nil {
-				errorResponse(w, http.StatusBadRequest, errors.New("invalid __v param"))
+				errorResponse(w, r, http.StatusBadRequest, errors.New("invalid __v param"))
 				return
 			}
 			t.Arguments["__v"] = v
@@ -303,29 +434,21 @@ func restGetDocumentHandler(storeName string) http.HandlerFunc {
 			t.Arguments["tokenInfo"] = cred.claims.toMap()
 		}
 
-		res, err := taskq.PushAndGetResult(&t, 0)
+		res, err := pushTask(r.Context(), &t)
 		taskTiming.End()
 		if err != nil {
-			if strings.EqualFold(err.Error(), "not found") {
-				errorResponse(w, http.StatusNotFound, err)
-				return
-			}
-
-			if strings.EqualFold(err.Error(), "unauthorized") {
-				jsonResponseWithStatus(w, http.StatusForbidden, err.Error())
-				return
-			}
-
-			errorResponse(w, http.StatusSeeOther, err)
+			errorResponse(w, r, 0, err)
 			return
 		}
 
 		totalTiming.End()
-		jsonResponse(w, res)
+		apiResponse(w, r, http.StatusOK, res)
 	}
 }
 
-func restPostDocumentHandler(storeName string) http.HandlerFunc {
+// restPostDocumentHandler creates the item. If insertOnly is true, existing item with the same _id is not replaced
+// and 409 is returned.
+func restPostDocumentHandler(storeName string, insertOnly bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		totalTiming := newServerTiming(w, "total")
 
@@ -333,26 +456,32 @@ func restPostDocumentHandler(storeName string) http.HandlerFunc {
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[rest post]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[rest post]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 		credTiming.End()
 
 		decodeTiming := newServerTiming(w, "decode")
 		var item map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-			errorResponse(w, http.StatusBadRequest, err)
+		if err := decodeRequestBody(w, r, &item); err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := validateStoreItem(storeName, item, false); err != nil {
+			errorResponse(w, r, 0, err)
 			return
 		}
 
-		if item["_id"] == nil {
+		hasID := item["_id"] != nil
+		if !hasID {
 			item["_id"] = uuid.NewV4()
 		}
 		decodeTiming.End()
@@ -370,26 +499,26 @@ func restPostDocumentHandler(storeName string) http.HandlerFunc {
 			t.Arguments["tokenInfo"] = cred.claims.toMap()
 		}
 
-		res, err := taskq.PushAndGetResult(&t, 0)
+		// duplicate _id error of the insert is responded with 409
+		if insertOnly {
+			t.Type = taskq.DbInsert
+		}
+
+		res, err := pushTask(r.Context(), &t)
 		taskTiming.End()
 		if err != nil {
-			if strings.EqualFold(err.Error(), "unauthorized") {
-				jsonResponseWithStatus(w, http.StatusForbidden, err.Error())
-				return
-			}
-
-			errorResponse(w, http.StatusSeeOther, err)
+			errorResponse(w, r, 0, err)
 			return
 		}
 
 		item, ok = res.(map[string]interface{})
 		if !ok {
-			errorResponse(w, http.StatusInternalServerError, berrors.ErrError)
+			errorResponse(w, r, http.StatusInternalServerError, berrors.ErrError)
 			return
 		}
 
 		totalTiming.End()
-		jsonResponseWithStatus(w, http.StatusCreated, item["_id"])
+		apiResponse(w, r, http.StatusCreated, item["_id"])
 	}
 }
 
@@ -401,14 +530,14 @@ func restPutDocumentHandler(storeName string) http.HandlerFunc {
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[rest put]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[rest put]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 		credTiming.End()
@@ -416,13 +545,18 @@ func restPutDocumentHandler(storeName string) http.HandlerFunc {
 		decodeTiming := newServerTiming(w, "decode")
 		id := chi.URLParam(r, "id")
 		if len(id) == 0 {
-			jsonResponseWithStatus(w, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+			errorResponse(w, r, http.StatusBadRequest, nil)
 			return
 		}
 
 		var item map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-			errorResponse(w, http.StatusBadRequest, err)
+		if err := decodeRequestBody(w, r, &item); err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := validateStoreItem(storeName, item, true); err != nil {
+			errorResponse(w, r, 0, err)
 			return
 		}
 
@@ -441,25 +575,15 @@ func restPutDocumentHandler(storeName string) http.HandlerFunc {
 		}
 
 		taskTiming := newServerTiming(w, "task")
-		if _, err := taskq.PushAndGetResult(&t, 0); err != nil {
+		if _, err := pushTask(r.Context(), &t); err != nil {
 			taskTiming.End()
-			if strings.EqualFold(err.Error(), "not found") {
-				jsonResponseWithStatus(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
-				return
-			}
-
-			if strings.EqualFold(err.Error(), "unauthorized") {
-				jsonResponseWithStatus(w, http.StatusForbidden, err.Error())
-				return
-			}
-
-			errorResponse(w, http.StatusSeeOther, err)
+			errorResponse(w, r, 0, err)
 			return
 		}
 		taskTiming.End()
 
 		totalTiming.End()
-		jsonResponse(w, http.StatusText(http.StatusOK))
+		apiResponse(w, r, http.StatusOK, http.StatusText(http.StatusOK))
 	}
 }
 
@@ -468,20 +592,20 @@ func restDeleteDocumentHandler(storeName string) http.HandlerFunc {
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[rest delete]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[rest delete]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		id := chi.URLParam(r, "id")
 		if len(id) == 0 {
-			jsonResponseWithStatus(w, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+			errorResponse(w, r, http.StatusBadRequest, nil)
 			return
 		}
 
@@ -497,22 +621,12 @@ func restDeleteDocumentHandler(storeName string) http.HandlerFunc {
 			t.Arguments["tokenInfo"] = cred.claims.toMap()
 		}
 
-		if _, err := taskq.PushAndGetResult(&t, 0); err != nil {
-			if strings.EqualFold(err.Error(), "not found") {
-				errorResponse(w, http.StatusNotFound, err)
-				return
-			}
-
-			if strings.EqualFold(err.Error(), "unauthorized") {
-				jsonResponseWithStatus(w, http.StatusForbidden, err.Error())
-				return
-			}
-
-			errorResponse(w, http.StatusSeeOther, err)
+		if _, err := pushTask(r.Context(), &t); err != nil {
+			errorResponse(w, r, 0, err)
 			return
 		}
 
-		jsonResponse(w, http.StatusText(http.StatusOK))
+		apiResponse(w, r, http.StatusOK, http.StatusText(http.StatusOK))
 	}
 }
 
@@ -525,6 +639,10 @@ func restCreateWidgetLoadData(store config.Store) {
 	r := r.With(jwtAuthMiddleware(false))
 	r.Get(uri, restWidgetLoadDataHandler(store))
 	log.Debugf("Created GET REST method %q", uri)
+
+	batchURI := fmt.Sprintf("%s%s/widgets/_load", apiV1baseURI, store.Store)
+	r.Post(batchURI, restWidgetsBatchLoadHandler(store))
+	log.Debugf("Created POST REST method %q", batchURI)
 }
 
 func restWidgetLoadDataHandler(store config.Store) http.HandlerFunc {
@@ -535,20 +653,20 @@ func restWidgetLoadDataHandler(store config.Store) http.HandlerFunc {
 		c := r.Context().Value(credKey)
 		if c == nil {
 			log.Warn("[rest get]: no cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		cred, ok := c.(credentials)
 		if !ok {
 			log.Warn("[rest get]: invalid cred in echo context")
-			jsonResponseWithStatus(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			errorResponse(w, r, http.StatusUnauthorized, nil)
 			return
 		}
 
 		widgetID := chi.URLParam(r, "widgetID")
 		if len(widgetID) == 0 {
-			jsonResponseWithStatus(w, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+			errorResponse(w, r, http.StatusBadRequest, nil)
 			return
 		}
 
@@ -561,7 +679,7 @@ func restWidgetLoadDataHandler(store config.Store) http.HandlerFunc {
 		}
 
 		if !found {
-			jsonResponseWithStatus(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+			errorResponse(w, r, http.StatusNotFound, nil)
 			return
 		}
 
@@ -571,7 +689,7 @@ func restWidgetLoadDataHandler(store config.Store) http.HandlerFunc {
 				var err error
 				itemID, err = strconv.Atoi(id)
 				if err != nil {
-					errorResponse(w, http.StatusBadRequest, err)
+					errorResponse(w, r, http.StatusBadRequest, err)
 					return
 				}
 			} else {
@@ -582,7 +700,7 @@ func restWidgetLoadDataHandler(store config.Store) http.HandlerFunc {
 		if d := r.URL.Query().Get("data"); len(d) > 0 {
 			err := json.Unmarshal([]byte(d), &data)
 			if err != nil {
-				errorResponse(w, http.StatusBadRequest, err)
+				errorResponse(w, r, http.StatusBadRequest, err)
 				return
 			}
 		}
@@ -601,22 +719,12 @@ func restWidgetLoadDataHandler(store config.Store) http.HandlerFunc {
 			t.Arguments["tokenInfo"] = cred.claims.toMap()
 		}
 
-		res, err := taskq.PushAndGetResult(&t, 0)
+		res, err := pushTask(r.Context(), &t)
 		if err != nil {
-			if strings.EqualFold(err.Error(), "not found") {
-				errorResponse(w, http.StatusNotFound, err)
-				return
-			}
-
-			if strings.EqualFold(err.Error(), "unauthorized") {
-				jsonResponseWithStatus(w, http.StatusForbidden, err.Error())
-				return
-			}
-
-			errorResponse(w, http.StatusSeeOther, err)
+			errorResponse(w, r, 0, err)
 			return
 		}
 
-		jsonResponse(w, res)
+		apiResponse(w, r, http.StatusOK, res)
 	}
 }
@@ -0,0 +1,122 @@
+package internet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 1000
+)
+
+var (
+	settings       = map[string]storeSettings{}
+	stores         = map[string]config.Store{}
+	settingsLocker sync.RWMutex
+)
+
+// storeSettings describes blank-one specific settings of the store.
+// Settings are taken from the "stores" entry of the _serverSettings store, e.g.:
+//
+//	"_serverSettings": {"entries": {"stores": {"users": {"maxPageSize": 100}}}}
+type storeSettings struct {
+	DefaultPageSize   int      `json:"defaultPageSize,omitempty"`
+	MaxPageSize       int      `json:"maxPageSize,omitempty"`
+	DisableValidation bool     `json:"disableValidation,omitempty"`
+	AsyncActions      []string `json:"asyncActions,omitempty"` // ids of actions which always run as jobs
+
+	// Task timeouts as durations, e.g. "30s". Timeout is used for all tasks of the store,
+	// TaskTimeouts are by task type and ActionTimeouts are by action id.
+	Timeout        string            `json:"timeout,omitempty"`
+	TaskTimeouts   map[string]string `json:"taskTimeouts,omitempty"`
+	ActionTimeouts map[string]string `json:"actionTimeouts,omitempty"`
+
+	Cache *cacheSettings `json:"cache,omitempty"`
+
+	// Hooks are settings of the http hooks by the hook uri
+	Hooks map[string]hookSettings `json:"hooks,omitempty"`
+
+	Uploads *uploadSettings `json:"uploads,omitempty"`
+
+	// Webhooks are subscriptions to the store events in addition to the ones created with API
+	Webhooks []webhookSettings `json:"webhooks,omitempty"`
+
+	timeout        time.Duration
+	taskTimeouts   map[string]time.Duration
+	actionTimeouts map[string]time.Duration
+}
+
+func loadStoreSettings(c map[string]config.Store) {
+	res := map[string]storeSettings{}
+	if ss, ok := c[config.ObjServerSettings]; ok && ss.Entries["stores"] != nil {
+		encoded, err := json.Marshal(ss.Entries["stores"])
+		if err != nil {
+			log.Errorf("Can't marshal stores settings, error: %v", err)
+		} else if err := json.Unmarshal(encoded, &res); err != nil {
+			log.Errorf("Can't unmarshal stores settings, error: %v", err)
+		}
+	}
+
+	for storeName, store := range c {
+		s := res[storeName]
+		if s.DefaultPageSize <= 0 {
+			s.DefaultPageSize = store.ItemsOnPage
+		}
+
+		if s.DefaultPageSize <= 0 {
+			s.DefaultPageSize = defaultPageSize
+		}
+
+		if s.MaxPageSize <= 0 {
+			s.MaxPageSize = maxPageSize
+		}
+
+		if s.DefaultPageSize > s.MaxPageSize {
+			s.DefaultPageSize = s.MaxPageSize
+		}
+
+		if len(s.Timeout) > 0 {
+			if d, err := time.ParseDuration(s.Timeout); err == nil {
+				s.timeout = d
+			} else {
+				log.Warnf("Invalid timeout %q in store %s settings, error: %v", s.Timeout, storeName, err)
+			}
+		}
+
+		s.taskTimeouts = parseTimeouts(storeName, s.TaskTimeouts)
+		s.actionTimeouts = parseTimeouts(storeName, s.ActionTimeouts)
+
+		res[storeName] = s
+	}
+
+	updateCaches(res)
+
+	settingsLocker.Lock()
+	settings = res
+	stores = c
+	settingsLocker.Unlock()
+}
+
+func getStoreSettings(storeName string) storeSettings {
+	settingsLocker.RLock()
+	defer settingsLocker.RUnlock()
+
+	s, ok := settings[storeName]
+	if !ok {
+		return storeSettings{DefaultPageSize: defaultPageSize, MaxPageSize: maxPageSize}
+	}
+
+	return s
+}
+
+// getStoreConfig returns description of the store from the last received config.
+func getStoreConfig(storeName string) (config.Store, bool) {
+	settingsLocker.RLock()
+	defer settingsLocker.RUnlock()
+
+	s, ok := stores[storeName]
+	return s, ok
+}
@@ -0,0 +1,72 @@
+package internet
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+// createRESTAPIForSingleStore creates REST API for the store of type "single". Such store has the only document
+// with _id equal to the store name, so there are no collection and /{id} routes:
+//
+//	GET /api/v1/{store}              returns the document
+//	PUT|PATCH /api/v1/{store}        updates the document
+//	POST /api/v1/{store}/{actionId}  runs the action on the document
+func createRESTAPIForSingleStore(store config.Store) {
+	log.Debugf("Creating REST API for single store %q", store.Store)
+	baseURI := apiV1baseURI + store.Store
+	lowerBaseURI := strings.ToLower(baseURI)
+
+	r := r.With(allowAnyOriginMiddleware, jwtAuthMiddleware(false), storeMiddleware(store.Store), singleItemMiddleware(store.Store))
+	r.Get(baseURI, restGetDocumentHandler(store.Store))
+	log.Debugf("Created GET single REST method %s", baseURI)
+	if baseURI != lowerBaseURI {
+		r.Get(lowerBaseURI, restGetDocumentHandler(store.Store))
+		log.Debugf("Created GET single REST method %s", lowerBaseURI)
+	}
+
+	r.Put(baseURI, idempotencyHandler(restPutDocumentHandler(store.Store)))
+	log.Debugf("Created PUT single REST method %s", baseURI)
+	if baseURI != lowerBaseURI {
+		r.Put(lowerBaseURI, idempotencyHandler(restPutDocumentHandler(store.Store)))
+		log.Debugf("Created PUT single REST method %s", lowerBaseURI)
+	}
+
+	r.Patch(baseURI, idempotencyHandler(restPutDocumentHandler(store.Store)))
+	log.Debugf("Created PATCH single REST method %s", baseURI)
+	if baseURI != lowerBaseURI {
+		r.Patch(lowerBaseURI, idempotencyHandler(restPutDocumentHandler(store.Store)))
+		log.Debugf("Created PATCH single REST method %s", lowerBaseURI)
+	}
+
+	actions := append(append([]config.Action{}, store.Actions...), store.StoreActions...)
+	for _, a := range actions {
+		actionURI := baseURI + "/" + a.ID
+		lowerActionURI := lowerBaseURI + "/" + strings.ToLower(a.ID)
+		r.Post(actionURI, idempotencyHandler(restActionHandler(store.Store, a.ID)))
+		log.Debugf("Created POST single action REST method %s", actionURI)
+		if actionURI != lowerActionURI {
+			r.Post(lowerActionURI, idempotencyHandler(restActionHandler(store.Store, a.ID)))
+			log.Debugf("Created POST single action REST method %s", lowerActionURI)
+		}
+	}
+
+	restCreateWidgetLoadData(store)
+}
+
+// singleItemMiddleware sets id URL param to the _id of the single store document,
+// so item handlers can be used for the single store routes.
+func singleItemMiddleware(storeName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				rctx.URLParams.Add("id", storeName)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package internet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestSingleItemMiddleware(t *testing.T) {
+	router := chi.NewRouter()
+	var id string
+	router.With(singleItemMiddleware("settings")).Get("/api/v1/settings", func(w http.ResponseWriter, r *http.Request) {
+		id = chi.URLParam(r, "id")
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/settings", nil))
+	if id != "settings" {
+		t.Fatalf("id URL param is %q, expected: %q", id, "settings")
+	}
+}
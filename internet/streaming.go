@@ -0,0 +1,221 @@
+package internet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/getblank/blank-one/intranet"
+	"github.com/getblank/blank-one/sr"
+)
+
+const (
+	resultTypeStream = "stream"
+	streamFormatSSE  = "sse"
+
+	streamCopyBufferSize = 32 << 10
+
+	// headerStreamError is the trailer with the error of the not completed stream
+	headerStreamError = "X-Stream-Error"
+)
+
+// streamResponse writes the stream result of the hook or action as soon as its chunks are ready:
+//
+//	{"type": "stream", "streamId": "..."}                   chunks written by the worker with stream.write and stream.end RPC
+//	{"type": "stream", "streamId": "...", "format": "sse"}  the same chunks written as server-sent events
+//	{"type": "stream", "store": "files", "_id": "..."}      file copied from the file store without buffering
+func streamResponse(w http.ResponseWriter, r *http.Request, res *result, code int) {
+	if len(res.StreamID) == 0 {
+		if len(res.Store) == 0 || len(res.ID) == 0 {
+			errorResponse(w, r, http.StatusInternalServerError, fmt.Errorf("stream result without streamId"))
+			return
+		}
+
+		streamFromFileStore(w, r, res, code)
+		return
+	}
+
+	s := intranet.OpenStream(res.StreamID)
+	defer s.Close()
+
+	sse := res.Format == streamFormatSSE
+	if sse {
+		w.Header().Set(headerContentType, textEventStream)
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		if len(w.Header().Get(headerContentType)) == 0 {
+			w.Header().Set(headerContentType, "application/octet-stream")
+		}
+
+		w.Header().Set("Trailer", headerStreamError)
+	}
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(code)
+
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	timeout := intranet.StreamTimeout()
+	wait := timeout
+	if sse && sseHeartbeatPeriod < wait {
+		wait = sseHeartbeatPeriod
+	}
+
+	var idle time.Duration
+	for {
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		chunk, err := s.Next(ctx)
+		cancel()
+		if err == context.DeadlineExceeded && r.Context().Err() == nil {
+			idle += wait
+			if idle >= timeout {
+				log.Warnf("[stream response] stream %s timed out", res.StreamID)
+				abortStream(w, sse, intranet.ErrStreamTimeout)
+				return
+			}
+
+			if sse {
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					log.Debugf("[stream response] write error: %v", err)
+					return
+				}
+
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+
+			continue
+		}
+
+		if r.Context().Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Debugf("[stream response] stream %s ended with error: %v", res.StreamID, err)
+			abortStream(w, sse, err)
+			return
+		}
+
+		if chunk == nil {
+			return
+		}
+
+		if sse {
+			_, err = w.Write(sseChunk(chunk))
+		} else {
+			_, err = w.Write(chunk.Data)
+		}
+		if err != nil {
+			log.Debugf("[stream response] write error: %v", err)
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+		idle = 0
+	}
+}
+
+// abortStream tells the client that the stream is not completed. SSE clients receive error event,
+// other streams get X-Stream-Error trailer, so the client doesn't take partial body as the complete one.
+func abortStream(w http.ResponseWriter, sse bool, err error) {
+	if !sse {
+		w.Header().Set(headerStreamError, err.Error())
+		return
+	}
+
+	encoded, _ := json.Marshal(err.Error())
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", encoded)
+}
+
+// sseChunk encodes the chunk as server-sent event, every line of the data is sent in its own data field.
+func sseChunk(c *intranet.StreamChunk) []byte {
+	var b bytes.Buffer
+	if len(c.ID) > 0 {
+		fmt.Fprintf(&b, "id: %s\n", c.ID)
+	}
+
+	if len(c.Event) > 0 {
+		fmt.Fprintf(&b, "event: %s\n", c.Event)
+	}
+
+	for _, line := range bytes.Split(bytes.TrimSuffix(c.Data, []byte("\n")), []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(bytes.TrimSuffix(line, []byte("\r")))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	return b.Bytes()
+}
+
+func streamFromFileStore(w http.ResponseWriter, r *http.Request, res *result, code int) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s/%s", sr.FSAddress(), res.Store, res.ID), nil)
+	if err != nil {
+		errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	fsRes, err := http.DefaultClient.Do(req.WithContext(r.Context()))
+	if err != nil {
+		errorResponse(w, r, 0, errFileStoreUnavailable)
+		return
+	}
+	defer fsRes.Body.Close()
+
+	if fsRes.StatusCode != http.StatusOK {
+		errorResponse(w, r, fsRes.StatusCode, nil)
+		return
+	}
+
+	for k, v := range fsRes.Header {
+		if k == "File-Name" || len(w.Header().Get(k)) > 0 {
+			continue
+		}
+
+		for _, h := range v {
+			w.Header().Add(k, h)
+		}
+	}
+
+	if len(res.FileName) > 0 {
+		w.Header().Set(headerContentDisposition, fmt.Sprintf("attachment; filename=%q", res.FileName))
+	}
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.Header().Set("Trailer", headerStreamError)
+	w.WriteHeader(code)
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, streamCopyBufferSize)
+	for {
+		n, err := fsRes.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				log.Debugf("[stream response] write error: %v", werr)
+				return
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			log.Errorf("[stream response] can't read file %s from the file store, error: %v", res.ID, err)
+			abortStream(w, false, err)
+			return
+		}
+	}
+}
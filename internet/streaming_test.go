@@ -0,0 +1,24 @@
+package internet
+
+import (
+	"testing"
+
+	"github.com/getblank/blank-one/intranet"
+)
+
+func TestSSEChunk(t *testing.T) {
+	testData := []struct {
+		chunk    intranet.StreamChunk
+		expected string
+	}{
+		{intranet.StreamChunk{Data: []byte("hello")}, "data: hello\n\n"},
+		{intranet.StreamChunk{Data: []byte("line 1\r\nline 2\n"), Event: "log", ID: "7"}, "id: 7\nevent: log\ndata: line 1\ndata: line 2\n\n"},
+		{intranet.StreamChunk{Event: "ping"}, "event: ping\ndata: \n\n"},
+	}
+
+	for _, v := range testData {
+		if res := string(sseChunk(&v.chunk)); res != v.expected {
+			t.Errorf("expected %q, got %q", v.expected, res)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package internet
 
 import (
+	"context"
+
 	"github.com/getblank/blank-router/berrors"
 	"github.com/getblank/blank-router/taskq"
 	"github.com/getblank/wango"
@@ -28,7 +30,7 @@ func subUserHandler(c *wango.Conn, uri string, args ...interface{}) (interface{}
 		t.Arguments["tokenInfo"] = cred.claims.toMap()
 	}
 
-	res, err := taskq.PushAndGetResult(&t, 0)
+	res, err := pushTask(context.Background(), &t)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +57,7 @@ func subConfigHandler(c *wango.Conn, uri string, args ...interface{}) (interface
 	}
 
 	log.Debugf("Config request received for client: \"%s\"", c.ID())
-	res, err := taskq.PushAndGetResult(&t, 0)
+	res, err := pushTask(context.Background(), &t)
 	log.Debugf("Config request completed for client: \"%s\"", c.ID())
 	if err != nil {
 		return nil, err
@@ -0,0 +1,151 @@
+package internet
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/getblank/blank-router/taskq"
+
+	"github.com/getblank/blank-one/intranet"
+)
+
+// statusClientClosedRequest is used when the client disconnected before the task was completed.
+const statusClientClosedRequest = 499
+
+var (
+	// defaultTaskTimeout is used for tasks without configured timeout. Zero means no timeout.
+	defaultTaskTimeout time.Duration
+	// taskTypeTimeouts are default timeouts by task type, e.g. BLANK_TASK_TIMEOUTS="dbFind=30s,action=5m"
+	taskTypeTimeouts = map[string]time.Duration{
+		taskq.SignOut:    30 * time.Second,
+		taskq.DidSignOut: 30 * time.Second,
+	}
+
+	errTaskCancelled = newAPIError(statusClientClosedRequest, "cancelled", "task cancelled")
+)
+
+// pushTask returns result of the task from the store cache or pushes it to the queue.
+// Successful write tasks invalidate the store cache.
+func pushTask(ctx context.Context, t *taskq.Task) (interface{}, error) {
+	c, key := taskCacheKey(t)
+	if c == nil {
+		res, err := pushTaskToQueue(ctx, t)
+		if err == nil && isWriteTask(t.Type) {
+			invalidateStoreCache(t.Store)
+		}
+
+		return res, err
+	}
+
+	if res, ok := c.get(key); ok {
+		return res, nil
+	}
+
+	generation := c.currentGeneration()
+	res, err := pushTaskToQueue(ctx, t)
+	if err == nil {
+		c.set(key, res, generation)
+	}
+
+	return res, err
+}
+
+// pushTaskToQueue pushes task to the queue and waits for the result until ctx is done or task timeout is reached.
+// The task is cancelled in both cases and the worker processing it is notified.
+func pushTaskToQueue(ctx context.Context, t *taskq.Task) (interface{}, error) {
+	if timeout := taskTimeout(t); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resChan := taskq.Push(t)
+	select {
+	case res := <-resChan:
+		if len(res.Err) > 0 {
+			return nil, errors.New(res.Err)
+		}
+
+		return res.Result, nil
+	case <-ctx.Done():
+		intranet.CancelTask(t.ID)
+		// result of the cancelled task must be received, otherwise the queue is blocked
+		go func() {
+			<-resChan
+			intranet.ForgetCancelledTask(t.ID)
+		}()
+
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Debugf("Task %d type %s for store %s timed out", t.ID, t.Type, t.Store)
+			return nil, taskq.ErrTimeout
+		}
+
+		log.Debugf("Task %d type %s for store %s cancelled", t.ID, t.Type, t.Store)
+		return nil, errTaskCancelled
+	}
+}
+
+// taskTimeout returns timeout for the task. Action timeout has the highest priority,
+// then store timeout for the task type, store timeout, and default timeouts by task type.
+func taskTimeout(t *taskq.Task) time.Duration {
+	s := getStoreSettings(t.Store)
+	if t.Type == taskq.DbAction {
+		if actionID, ok := t.Arguments["actionId"].(string); ok {
+			if timeout, ok := s.actionTimeouts[actionID]; ok {
+				return timeout
+			}
+		}
+	}
+
+	if timeout, ok := s.taskTimeouts[t.Type]; ok {
+		return timeout
+	}
+
+	if s.timeout > 0 {
+		return s.timeout
+	}
+
+	if timeout, ok := taskTypeTimeouts[t.Type]; ok {
+		return timeout
+	}
+
+	return defaultTaskTimeout
+}
+
+// parseTimeouts parses durations map from settings. Invalid values are ignored.
+func parseTimeouts(storeName string, timeouts map[string]string) map[string]time.Duration {
+	res := make(map[string]time.Duration, len(timeouts))
+	for k, v := range timeouts {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Warnf("Invalid timeout %q for %q in store %s settings, error: %v", v, k, storeName, err)
+			continue
+		}
+
+		res[k] = d
+	}
+
+	return res
+}
+
+func init() {
+	if s := os.Getenv("BLANK_TASK_TIMEOUT"); len(s) > 0 {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			defaultTaskTimeout = d
+		}
+	}
+
+	for _, pair := range strings.Split(os.Getenv("BLANK_TASK_TIMEOUTS"), ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		if d, err := time.ParseDuration(kv[1]); err == nil && d > 0 {
+			taskTypeTimeouts[kv[0]] = d
+		}
+	}
+}
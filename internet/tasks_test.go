@@ -0,0 +1,58 @@
+package internet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/getblank/blank-router/taskq"
+)
+
+func TestTaskTimeout(t *testing.T) {
+	settingsLocker.Lock()
+	settings["timeoutsTestStore"] = storeSettings{
+		timeout:        10 * time.Second,
+		taskTimeouts:   parseTimeouts("timeoutsTestStore", map[string]string{"dbFind": "20s", "dbGet": "invalid"}),
+		actionTimeouts: parseTimeouts("timeoutsTestStore", map[string]string{"report": "5m"}),
+	}
+	settingsLocker.Unlock()
+	defer func() {
+		settingsLocker.Lock()
+		delete(settings, "timeoutsTestStore")
+		settingsLocker.Unlock()
+	}()
+
+	testData := []struct {
+		task *taskq.Task
+		res  time.Duration
+	}{
+		{&taskq.Task{Store: "timeoutsTestStore", Type: taskq.DbAction, Arguments: map[string]interface{}{"actionId": "report"}}, 5 * time.Minute},
+		{&taskq.Task{Store: "timeoutsTestStore", Type: taskq.DbAction, Arguments: map[string]interface{}{"actionId": "other"}}, 10 * time.Second},
+		{&taskq.Task{Store: "timeoutsTestStore", Type: taskq.DbFind}, 20 * time.Second},
+		{&taskq.Task{Store: "timeoutsTestStore", Type: taskq.DbGet}, 10 * time.Second},
+		{&taskq.Task{Store: "unknownStore", Type: taskq.SignOut}, 30 * time.Second},
+		{&taskq.Task{Store: "unknownStore", Type: taskq.DbGet}, defaultTaskTimeout},
+	}
+
+	for _, v := range testData {
+		if res := taskTimeout(v.task); res != v.res {
+			t.Errorf("taskTimeout(%s, %s) = %v, expected %v", v.task.Type, v.task.Arguments["actionId"], res, v.res)
+		}
+	}
+}
+
+// serveTasks takes n tasks from the queue as the worker does and responds with the results of fn.
+// Tasks are sent to the channel after they are done.
+func serveTasks(n int, fn func(t *taskq.Task) (interface{}, string)) <-chan *taskq.Task {
+	served := make(chan *taskq.Task, n)
+	go func() {
+		for i := 0; i < n; i++ {
+			t := taskq.Shift()
+			res, err := fn(t)
+			taskq.Done(taskq.Result{ID: t.ID, Result: res, Err: err})
+			served <- t
+		}
+		close(served)
+	}()
+
+	return served
+}
@@ -0,0 +1,342 @@
+package internet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getblank/blank-one/sr"
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/uuid"
+)
+
+const (
+	// uploadTempStore is the file store for uploads of stores without target store in the settings
+	uploadTempStore     = "_uploads"
+	uploadsBucket       = "temp-uploads"
+	uploadCleanupPeriod = time.Hour
+	uploadMaxFormSize   = 10 << 20
+	uploadBodyField     = "body"
+)
+
+var (
+	defaultUploadMaxFileSize  int64 = 32 << 20
+	defaultUploadMaxTotalSize int64 = 100 << 20
+	defaultUploadMaxFiles           = 20
+	uploadTempTTL                   = 24 * time.Hour
+	uploadClient                    = &http.Client{}
+
+	errFileStoreUnavailable = newAPIError(http.StatusServiceUnavailable, "file_store_unavailable", "file store is not available")
+	errUploadLimit          = errors.New("upload limit exceeded")
+)
+
+// uploadSettings sets how files uploaded to the hooks and actions of the store are forwarded to the file store.
+// Without uploads settings multipart forms are parsed as before and files are not passed to the task, e.g.:
+//
+//	"uploads": {"store": "attachments", "maxFileSize": 10485760, "maxFiles": 5}
+//
+// Files are saved to the Store or to the temporary _uploads store, which is cleaned up after BLANK_UPLOAD_TTL.
+// With StreamBody other binary request bodies are forwarded to the file store too, instead of inlining them as base64.
+type uploadSettings struct {
+	Store        string `json:"store,omitempty"`
+	MaxFileSize  int64  `json:"maxFileSize,omitempty"`
+	MaxTotalSize int64  `json:"maxTotalSize,omitempty"`
+	MaxFiles     int    `json:"maxFiles,omitempty"`
+	StreamBody   bool   `json:"streamBody,omitempty"`
+}
+
+// uploadedFile is the reference to the uploaded file which is passed to the task instead of its content.
+type uploadedFile struct {
+	Field       string `json:"field"`
+	ID          string `json:"id"`
+	Store       string `json:"store"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+func (s *uploadSettings) limits() (maxFileSize, maxTotalSize int64, maxFiles int) {
+	maxFileSize, maxTotalSize, maxFiles = defaultUploadMaxFileSize, defaultUploadMaxTotalSize, defaultUploadMaxFiles
+	if s == nil {
+		return
+	}
+
+	if s.MaxFileSize > 0 {
+		maxFileSize = s.MaxFileSize
+	}
+
+	if s.MaxTotalSize > 0 {
+		maxTotalSize = s.MaxTotalSize
+	}
+
+	if s.MaxFiles > 0 {
+		maxFiles = s.MaxFiles
+	}
+
+	return
+}
+
+func (s *uploadSettings) store() string {
+	if s == nil || len(s.Store) == 0 {
+		return uploadTempStore
+	}
+
+	return s.Store
+}
+
+func uploadTooLarge(msg string) error {
+	return newAPIError(http.StatusRequestEntityTooLarge, "request_too_large", msg)
+}
+
+// limitedReader returns errUploadLimit instead of EOF when more than n bytes are read.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errUploadLimit
+	}
+
+	return n, err
+}
+
+// countingReader counts bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// extractMultipartUploads reads multipart form of the request. Form values are returned as the form,
+// files are streamed to the file store one by one without buffering in memory or on disk.
+func extractMultipartUploads(r *http.Request, s *uploadSettings) (url.Values, []uploadedFile, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	maxFileSize, maxTotalSize, maxFiles := s.limits()
+	form := url.Values{}
+	files := []uploadedFile{}
+	var formSize, totalSize int64
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			removeUploadedFiles(files)
+			return nil, nil, err
+		}
+
+		if len(part.FileName()) == 0 {
+			value, err := ioutil.ReadAll(io.LimitReader(part, uploadMaxFormSize-formSize+1))
+			part.Close()
+			if err != nil {
+				removeUploadedFiles(files)
+				return nil, nil, err
+			}
+
+			formSize += int64(len(value))
+			if formSize > uploadMaxFormSize {
+				removeUploadedFiles(files)
+				return nil, nil, uploadTooLarge("form values are too large")
+			}
+
+			form.Add(part.FormName(), string(value))
+			continue
+		}
+
+		if len(files) == maxFiles {
+			part.Close()
+			removeUploadedFiles(files)
+			return nil, nil, uploadTooLarge(fmt.Sprintf("too many files, max is %d", maxFiles))
+		}
+
+		limit := maxFileSize
+		if rest := maxTotalSize - totalSize; rest < limit {
+			limit = rest
+		}
+
+		f, err := uploadToFileStore(s.store(), part.FormName(), part.FileName(), part.Header.Get(headerContentType), part, limit)
+		part.Close()
+		if err != nil {
+			removeUploadedFiles(files)
+			if err == errUploadLimit {
+				return nil, nil, uploadTooLarge(fmt.Sprintf("file %q is too large, max file size is %d and max total size is %d bytes", part.FileName(), maxFileSize, maxTotalSize))
+			}
+
+			return nil, nil, err
+		}
+
+		totalSize += f.Size
+		files = append(files, *f)
+	}
+
+	return form, files, nil
+}
+
+// uploadToFileStore streams the content to the file store with the same HTTP API as the file store handlers use.
+func uploadToFileStore(storeName, field, fileName, contentType string, content io.Reader, limit int64) (*uploadedFile, error) {
+	fsAddress := sr.FSAddress()
+	if len(fsAddress) == 0 {
+		return nil, errFileStoreUnavailable
+	}
+
+	if len(contentType) == 0 {
+		contentType = mime.TypeByExtension(filepath.Ext(fileName))
+	}
+
+	id := uuid.NewV4()
+	body := &countingReader{r: &limitedReader{r: content, n: limit}}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s/%s", fsAddress, storeName, id), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("File-Name", fileName)
+	req.Header.Set(headerContentDisposition, fmt.Sprintf(`attachment; filename=%q`, fileName))
+	if len(contentType) > 0 {
+		req.Header.Set(headerContentType, contentType)
+	}
+
+	res, err := uploadClient.Do(req)
+	if err != nil {
+		if body.n > limit {
+			deleteFromFileStore(storeName, id)
+			return nil, errUploadLimit
+		}
+
+		log.Errorf("[uploads] can't upload file to the file store, error: %v", err)
+		return nil, errFileStoreUnavailable
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		log.Errorf("[uploads] file store responded with status %s", res.Status)
+		return nil, errFileStoreUnavailable
+	}
+
+	f := &uploadedFile{Field: field, ID: id, Store: storeName, Name: fileName, Size: body.n, ContentType: contentType}
+	if storeName == uploadTempStore {
+		if err := localDB.Save(uploadsBucket, id, []byte(time.Now().Add(uploadTempTTL).Format(time.RFC3339))); err != nil {
+			log.Errorf("[uploads] can't save temporary upload %s, error: %v", id, err)
+		}
+	}
+
+	return f, nil
+}
+
+func deleteFromFileStore(storeName, id string) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/%s/%s", sr.FSAddress(), storeName, id), nil)
+	if err != nil {
+		return
+	}
+
+	res, err := uploadClient.Do(req)
+	if err != nil {
+		log.Errorf("[uploads] can't delete file %s from the file store, error: %v", id, err)
+		return
+	}
+	res.Body.Close()
+
+	if storeName == uploadTempStore {
+		localDB.Delete(uploadsBucket, id)
+	}
+}
+
+// removeTaskUploads removes files uploaded to the target store of the failed hook or action task.
+// Files of the temporary store are removed by uploadsCleaner.
+func removeTaskUploads(t *taskq.Task) {
+	request, _ := t.Arguments["request"].(map[string]interface{})
+	files, _ := request["files"].([]uploadedFile)
+	for _, f := range files {
+		if f.Store != uploadTempStore {
+			deleteFromFileStore(f.Store, f.ID)
+		}
+	}
+}
+
+// removeUploadedFiles removes files of the failed request.
+func removeUploadedFiles(files []uploadedFile) {
+	for _, f := range files {
+		deleteFromFileStore(f.Store, f.ID)
+	}
+}
+
+// isMultipartForm reports if the request has multipart/form-data body.
+func isMultipartForm(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get(headerContentType))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// isTextBody reports if the request body is passed to the task as is, other bodies are base64 encoded or streamed to the file store.
+func isTextBody(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/plain")
+}
+
+// uploadsCleaner removes expired files of the temporary uploads store.
+func uploadsCleaner() {
+	for range time.Tick(uploadCleanupPeriod) {
+		ids, err := localDB.GetAllKeys(uploadsBucket)
+		if err != nil {
+			continue
+		}
+
+		for _, id := range ids {
+			data, err := localDB.Get(uploadsBucket, id)
+			if err != nil {
+				continue
+			}
+
+			if expiresAt, err := time.Parse(time.RFC3339, string(data)); err == nil && time.Now().After(expiresAt) {
+				deleteFromFileStore(uploadTempStore, id)
+			}
+		}
+	}
+}
+
+func init() {
+	if s := os.Getenv("BLANK_UPLOAD_MAX_FILE_SIZE"); len(s) > 0 {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+			defaultUploadMaxFileSize = n
+		}
+	}
+
+	if s := os.Getenv("BLANK_UPLOAD_MAX_TOTAL_SIZE"); len(s) > 0 {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+			defaultUploadMaxTotalSize = n
+		}
+	}
+
+	if s := os.Getenv("BLANK_UPLOAD_MAX_FILES"); len(s) > 0 {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			defaultUploadMaxFiles = n
+		}
+	}
+
+	if s := os.Getenv("BLANK_UPLOAD_TTL"); len(s) > 0 {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			uploadTempTTL = d
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package internet
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestLimitedReader(t *testing.T) {
+	b, err := ioutil.ReadAll(&limitedReader{r: strings.NewReader("12345"), n: 5})
+	if err != nil || string(b) != "12345" {
+		t.Fatalf("expected full content without error, got %q, %v", b, err)
+	}
+
+	if _, err := ioutil.ReadAll(&limitedReader{r: strings.NewReader("123456"), n: 5}); err != errUploadLimit {
+		t.Fatalf("expected errUploadLimit, got %v", err)
+	}
+}
+
+func TestUploadSettingsLimits(t *testing.T) {
+	var s *uploadSettings
+	if f, total, n := s.limits(); f != defaultUploadMaxFileSize || total != defaultUploadMaxTotalSize || n != defaultUploadMaxFiles {
+		t.Fatalf("expected default limits, got %d, %d, %d", f, total, n)
+	}
+
+	if s.store() != uploadTempStore {
+		t.Fatalf("expected temporary store, got %q", s.store())
+	}
+
+	s = &uploadSettings{Store: "attachments", MaxFileSize: 10, MaxFiles: 2}
+	if f, total, n := s.limits(); f != 10 || total != defaultUploadMaxTotalSize || n != 2 {
+		t.Fatalf("unexpected limits %d, %d, %d", f, total, n)
+	}
+
+	if s.store() != "attachments" {
+		t.Fatalf("expected attachments store, got %q", s.store())
+	}
+}
+
+func TestIsMultipartForm(t *testing.T) {
+	r := httptest.NewRequest("POST", "/hooks/orders/upload", nil)
+	r.Header.Set(headerContentType, "multipart/form-data; boundary=xyz")
+	if !isMultipartForm(r) {
+		t.Fatal("expected multipart form")
+	}
+
+	r.Header.Set(headerContentType, "application/x-www-form-urlencoded")
+	if isMultipartForm(r) {
+		t.Fatal("unexpected multipart form")
+	}
+}
+
+// Without uploads settings multipart form is parsed as before and files are not forwarded to the file store.
+func TestExtractRequestWithoutUploads(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("data", "42")
+	fw, _ := mw.CreateFormFile("file", "report.txt")
+	fw.Write([]byte("content"))
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/hooks/no-uploads/upload", &body)
+	r.Header.Set(headerContentType, mw.FormDataContentType())
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chi.NewRouteContext()))
+
+	request, err := extractRequest(r, "no-uploads")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if form, _ := request["form"].(url.Values); form.Get("data") != "42" {
+		t.Fatalf("expected form value, got %v", request["form"])
+	}
+
+	if _, ok := request["files"]; ok {
+		t.Fatal("unexpected files in the request")
+	}
+}
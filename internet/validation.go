@@ -0,0 +1,296 @@
+package internet
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+const (
+	validationCodeType      = "type"
+	validationCodeRequired  = "required"
+	validationCodeMinLength = "minLength"
+	validationCodeMaxLength = "maxLength"
+	validationCodeMin       = "min"
+	validationCodeMax       = "max"
+	validationCodePattern   = "pattern"
+	validationCodeOptions   = "options"
+)
+
+var (
+	patterns       = map[string]*regexp.Regexp{}
+	patternsLocker sync.Mutex
+)
+
+// validateStoreItem validates item before pushing write task for the store.
+// It returns 422 apiError with per-field errors, or nil if item is valid or validation is disabled for the store.
+// For partial updates required props are checked only if they are present in the item.
+func validateStoreItem(storeName string, item map[string]interface{}, partial bool) error {
+	if getStoreSettings(storeName).DisableValidation {
+		return nil
+	}
+
+	store, ok := getStoreConfig(storeName)
+	if !ok {
+		return nil
+	}
+
+	errs := validateProps(store.Props, item, "", partial)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return newAPIError(http.StatusUnprocessableEntity, "validation_failed", "item validation failed", errs...)
+}
+
+func validateProps(props map[string]config.Prop, item map[string]interface{}, prefix string, partial bool) []fieldError {
+	var errs []fieldError
+	for name, p := range props {
+		field := prefix + name
+		v, present := item[name]
+		if isEmptyValue(v) {
+			// omitted props with default value are filled by the workers
+			if required, _ := p.Required.(bool); required && name != "_id" && (present || (!partial && p.Default == nil)) {
+				errs = append(errs, fieldError{field, validationCodeRequired, fmt.Sprintf("%s is required", field)})
+			}
+
+			continue
+		}
+
+		errs = append(errs, validateValue(p, v, field, partial)...)
+	}
+
+	return errs
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return len(val) == 0
+	}
+
+	return false
+}
+
+func validateValue(p config.Prop, v interface{}, field string, partial bool) []fieldError {
+	typeError := func(expected string) []fieldError {
+		return []fieldError{{field, validationCodeType, fmt.Sprintf("%s must be %s", field, expected)}}
+	}
+
+	switch p.Type {
+	case config.PropInt, config.PropFloat:
+		n, ok := numberValue(v)
+		if !ok {
+			return typeError("a number")
+		}
+
+		if p.Type == config.PropInt && n != math.Trunc(n) {
+			return typeError("an integer")
+		}
+
+		var errs []fieldError
+		if min, ok := numberLimit(p.Min); ok && n < min {
+			errs = append(errs, fieldError{field, validationCodeMin, fmt.Sprintf("%s must be greater than or equal to %v", field, min)})
+		}
+
+		if max, ok := numberLimit(p.Max); ok && n > max {
+			errs = append(errs, fieldError{field, validationCodeMax, fmt.Sprintf("%s must be less than or equal to %v", field, max)})
+		}
+
+		return append(errs, validateOptions(p, v, field)...)
+	case config.PropBool:
+		if _, ok := v.(bool); !ok {
+			return typeError("a boolean")
+		}
+	case config.PropString, config.PropUUID, config.PropPassword:
+		s, ok := v.(string)
+		if !ok {
+			return typeError("a string")
+		}
+
+		var errs []fieldError
+		length := utf8.RuneCountInString(s)
+		if p.MinLength > 0 && length < p.MinLength {
+			errs = append(errs, fieldError{field, validationCodeMinLength, fmt.Sprintf("%s must be at least %d characters long", field, p.MinLength)})
+		}
+
+		if p.MaxLength > 0 && length > p.MaxLength {
+			errs = append(errs, fieldError{field, validationCodeMaxLength, fmt.Sprintf("%s must be at most %d characters long", field, p.MaxLength)})
+		}
+
+		if rgx := propPattern(p.Pattern); rgx != nil && !rgx.MatchString(s) {
+			msg := p.PatternError
+			if len(msg) == 0 {
+				msg = fmt.Sprintf("%s doesn't match the pattern", field)
+			}
+
+			errs = append(errs, fieldError{field, validationCodePattern, msg})
+		}
+
+		return append(errs, validateOptions(p, v, field)...)
+	case config.PropDate, config.PropDateOnly:
+		if _, ok := v.(time.Time); ok {
+			break
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			return typeError("an RFC 3339 date")
+		}
+
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			if _, err := time.Parse("2006-01-02", s); err != nil {
+				return typeError("an RFC 3339 date")
+			}
+		}
+	case config.PropRef:
+		if !isIDValue(v) {
+			return typeError("an id")
+		}
+	case config.PropRefList:
+		list, ok := v.([]interface{})
+		if !ok {
+			return typeError("an array of ids")
+		}
+
+		for _, id := range list {
+			if !isIDValue(id) {
+				return typeError("an array of ids")
+			}
+		}
+	case config.PropObject:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return typeError("an object")
+		}
+
+		return validateProps(p.Props, m, field+".", partial)
+	case config.PropObjectList:
+		list, ok := v.([]interface{})
+		if !ok {
+			return typeError("an array of objects")
+		}
+
+		var errs []fieldError
+		for i, el := range list {
+			m, ok := el.(map[string]interface{})
+			if !ok {
+				errs = append(errs, fieldError{fmt.Sprintf("%s.%d", field, i), validationCodeType, fmt.Sprintf("%s.%d must be an object", field, i)})
+				continue
+			}
+
+			// new list elements are always validated completely
+			errs = append(errs, validateProps(p.Props, m, fmt.Sprintf("%s.%d.", field, i), false)...)
+		}
+
+		return errs
+	}
+
+	return nil
+}
+
+// validateOptions checks that value is one of the prop options. Options can be values or objects with value.
+func validateOptions(p config.Prop, v interface{}, field string) []fieldError {
+	if len(p.Options) == 0 {
+		return nil
+	}
+
+	for _, o := range p.Options {
+		if m, ok := o.(map[string]interface{}); ok {
+			o = m["value"]
+		}
+
+		if fmt.Sprint(o) == fmt.Sprint(v) {
+			return nil
+		}
+	}
+
+	return []fieldError{{field, validationCodeOptions, fmt.Sprintf("%s must be one of the allowed options", field)}}
+}
+
+// numberValue returns number from decoded JSON or from the value coerced by import.
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+
+	return 0, false
+}
+
+func isIDValue(v interface{}) bool {
+	if _, ok := v.(string); ok {
+		return true
+	}
+
+	_, ok := numberValue(v)
+	return ok
+}
+
+func numberLimit(v interface{}) (float64, bool) {
+	if n, ok := numberValue(v); ok {
+		return n, true
+	}
+
+	switch n := v.(type) {
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// propPattern returns compiled pattern of the string prop. Pattern can be a string
+// or an object with expression and flags. Patterns which can't be compiled are ignored.
+func propPattern(pattern interface{}) *regexp.Regexp {
+	var expr, flags string
+	switch pt := pattern.(type) {
+	case string:
+		expr = pt
+	case map[string]interface{}:
+		expr, _ = pt["expression"].(string)
+		flags, _ = pt["flags"].(string)
+	}
+
+	if len(expr) == 0 {
+		return nil
+	}
+
+	if strings.Contains(flags, "i") {
+		expr = "(?i)" + expr
+	}
+
+	patternsLocker.Lock()
+	defer patternsLocker.Unlock()
+
+	rgx, ok := patterns[expr]
+	if !ok {
+		var err error
+		if rgx, err = regexp.Compile(expr); err != nil {
+			log.Debugf("Can't compile prop pattern %q, error: %v", expr, err)
+		}
+
+		patterns[expr] = rgx
+	}
+
+	return rgx
+}
@@ -0,0 +1,73 @@
+package internet
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+func TestValidateProps(t *testing.T) {
+	props := map[string]config.Prop{
+		"name":   {Type: config.PropString, Required: true, MinLength: 2, MaxLength: 5},
+		"code":   {Type: config.PropString, Pattern: map[string]interface{}{"expression": "^[a-z]+$", "flags": "i"}, PatternError: "letters only"},
+		"age":    {Type: config.PropInt, Min: 0, Max: float64(150)},
+		"status": {Type: config.PropString, Options: []interface{}{map[string]interface{}{"value": "new"}, "done"}},
+		"born":   {Type: config.PropDate},
+		"kind":   {Type: config.PropString, Required: true, Default: "person"},
+		"tags":   {Type: config.PropRefList, Store: "tags"},
+		"address": {Type: config.PropObject, Props: map[string]config.Prop{
+			"city": {Type: config.PropString, Required: true},
+		}},
+		"phones": {Type: config.PropObjectList, Props: map[string]config.Prop{
+			"number": {Type: config.PropString, Required: true},
+		}},
+	}
+
+	testData := []struct {
+		item    string
+		partial bool
+		fields  []string
+	}{
+		{`{"name": "John", "code": "ABc", "age": 42, "status": "new", "born": "2000-01-02", "tags": ["a", 1]}`, false, nil},
+		{`{}`, false, []string{"name:required"}},
+		{`{}`, true, nil},
+		{`{"name": "John", "kind": null}`, false, []string{"kind:required"}},
+		{`{"name": null}`, true, []string{"name:required"}},
+		{`{"name": "J"}`, false, []string{"name:minLength"}},
+		{`{"name": "Johnny"}`, false, []string{"name:maxLength"}},
+		{`{"name": 1}`, false, []string{"name:type"}},
+		{`{"code": "a1"}`, true, []string{"code:pattern"}},
+		{`{"age": 1.5}`, true, []string{"age:type"}},
+		{`{"age": -1}`, true, []string{"age:min"}},
+		{`{"age": 151}`, true, []string{"age:max"}},
+		{`{"age": "1"}`, true, []string{"age:type"}},
+		{`{"status": "old"}`, true, []string{"status:options"}},
+		{`{"status": "done"}`, true, nil},
+		{`{"born": "yesterday"}`, true, []string{"born:type"}},
+		{`{"tags": [{}]}`, true, []string{"tags:type"}},
+		{`{"address": {}}`, false, []string{"address.city:required", "name:required"}},
+		{`{"address": {}}`, true, nil},
+		{`{"address": "Moscow"}`, true, []string{"address:type"}},
+		{`{"phones": [{"number": "1"}, {}, 1]}`, true, []string{"phones.1.number:required", "phones.2:type"}},
+	}
+
+	for i, v := range testData {
+		var item map[string]interface{}
+		if err := json.Unmarshal([]byte(v.item), &item); err != nil {
+			t.Fatal(err)
+		}
+
+		var fields []string
+		errs := validateProps(props, item, "", v.partial)
+		for _, e := range errs {
+			fields = append(fields, e.Field+":"+e.Code)
+		}
+
+		sort.Strings(fields)
+		if !reflect.DeepEqual(fields, v.fields) {
+			t.Fatalf("%d: expected errors %v, got %v", i, v.fields, errs)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package internet
 
 import (
+	"context"
 	"errors"
 	"os"
 	"strings"
@@ -110,6 +111,15 @@ func wampInit() {
 }
 
 func onSREvent(uri string, event interface{}, subscribers []string) {
+	onCacheEvent(uri)
+	onWebhookEvent(uri, event)
+	onNotificationEvent(uri, event)
+	if strings.HasPrefix(uri, uriJobs+".") {
+		onJobEvent(uri, event)
+		return
+	}
+
+	sseHub.publish(uri, event, subscribers)
 	if subscribers == nil {
 		wamp.Publish(uri, event)
 		return
@@ -185,14 +195,22 @@ func actionHandler(c *wango.Conn, uri string, args ...interface{}) (interface{},
 	if len(args) > 3 {
 		t.Arguments["data"] = args[3]
 	}
-	resChan := taskq.Push(&t)
+	return pushWAMPTask(&t)
+}
 
-	res := <-resChan
-	if res.Err != "" {
-		return nil, errors.New(res.Err)
+// pushWAMPTask pushes the task of WAMP RPC. Structured worker errors are returned to WAMP clients
+// with their message only, as the clients expect plain error descriptions.
+func pushWAMPTask(t *taskq.Task) (interface{}, error) {
+	res, err := pushTask(context.Background(), t)
+	if err != nil {
+		if e, ok := parseWorkerError(err.Error()); ok {
+			return nil, errors.New(e.Message)
+		}
+
+		return nil, err
 	}
 
-	return res.Result, nil
+	return res, nil
 }
 
 func checkUserWAMPHandler(c *wango.Conn, uri string, args ...interface{}) (interface{}, error) {
@@ -212,7 +230,7 @@ func checkUserWAMPHandler(c *wango.Conn, uri string, args ...interface{}) (inter
 			},
 		},
 	}
-	_res, err := taskq.PushAndGetResult(&t, 0)
+	_res, err := pushTask(context.Background(), &t)
 	if err != nil {
 		return "USER_NOT_FOUND", nil
 	}
@@ -274,19 +292,19 @@ func rgxRPCHandler(c *wango.Conn, uri string, args ...interface{}) (interface{},
 		case "get":
 			t.Type = taskq.DbGet
 			t.Arguments = map[string]interface{}{"_id": args[0]}
-			return taskq.PushAndGetResult(&t, 0)
+			return pushWAMPTask(&t)
 		case "save":
 			t.Type = taskq.DbSet
 			t.Arguments = map[string]interface{}{"item": args[0]}
-			return taskq.PushAndGetResult(&t, 0)
+			return pushWAMPTask(&t)
 		case "insert":
 			t.Type = taskq.DbInsert
 			t.Arguments = map[string]interface{}{"item": args[0]}
-			return taskq.PushAndGetResult(&t, 0)
+			return pushWAMPTask(&t)
 		case "delete":
 			t.Type = taskq.DbDelete
 			t.Arguments = map[string]interface{}{"_id": args[0]}
-			return taskq.PushAndGetResult(&t, 0)
+			return pushWAMPTask(&t)
 		case "push":
 			if len(args) < 3 {
 				return nil, berrors.ErrInvalidArguments
@@ -297,7 +315,7 @@ func rgxRPCHandler(c *wango.Conn, uri string, args ...interface{}) (interface{},
 				"prop": args[1],
 				"data": args[2],
 			}
-			return taskq.PushAndGetResult(&t, 0)
+			return pushWAMPTask(&t)
 		case "load-refs":
 			if len(args) < 4 {
 				return nil, berrors.ErrInvalidArguments
@@ -309,13 +327,13 @@ func rgxRPCHandler(c *wango.Conn, uri string, args ...interface{}) (interface{},
 				"selected": args[2],
 				"query":    args[3],
 			}
-			return taskq.PushAndGetResult(&t, 0)
+			return pushWAMPTask(&t)
 		case "find":
 			t.Type = taskq.DbFind
 			t.Arguments = map[string]interface{}{
 				"query": args[0],
 			}
-			return taskq.PushAndGetResult(&t, 0)
+			return pushWAMPTask(&t)
 		case "widget-data":
 			if len(args) < 3 {
 				return nil, berrors.ErrInvalidArguments
@@ -326,7 +344,7 @@ func rgxRPCHandler(c *wango.Conn, uri string, args ...interface{}) (interface{},
 				"data":     args[1],
 				"itemId":   args[2],
 			}
-			return taskq.PushAndGetResult(&t, 0)
+			return pushWAMPTask(&t)
 		}
 	}
 	return nil, errUnknownMethod
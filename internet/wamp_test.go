@@ -0,0 +1,25 @@
+package internet
+
+import (
+	"testing"
+
+	"github.com/getblank/blank-router/taskq"
+)
+
+func TestPushWAMPTaskErrors(t *testing.T) {
+	testData := []struct {
+		err      string
+		expected string
+	}{
+		{`{"status":422,"code":"validation_failed","message":"name is required"}`, "name is required"},
+		{"404 item not found", "404 item not found"},
+	}
+
+	for _, v := range testData {
+		serveTasks(1, func(*taskq.Task) (interface{}, string) { return nil, v.err })
+		_, err := pushWAMPTask(&taskq.Task{Type: taskq.DbSet, Store: "wampTestStore"})
+		if err == nil || err.Error() != v.expected {
+			t.Errorf("expected error %q, got %v", v.expected, err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package internet
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/getblank/uuid"
+	"github.com/go-chi/chi"
+)
+
+const (
+	webhooksURI                = apiV1baseURI + "_webhooks"
+	webhookDefaultDeliveryTake = 50
+	webhookSecretMask          = "********"
+)
+
+type webhookSubscriptionRequest struct {
+	URL    string   `json:"url"`
+	Store  string   `json:"store"`
+	Events []string `json:"events,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+// initWebhookRoutes creates routes for managing webhook subscriptions. Only root can manage them,
+// because webhooks receive store events regardless of the user permissions.
+//
+//	GET    /api/v1/_webhooks                                            subscriptions from the settings and API
+//	POST   /api/v1/_webhooks                                            creates subscription
+//	GET    /api/v1/_webhooks/{id}                                       subscription
+//	DELETE /api/v1/_webhooks/{id}                                       deletes subscription created with API
+//	GET    /api/v1/_webhooks/{id}/deliveries                            delivery log, newest first
+//	POST   /api/v1/_webhooks/{id}/deliveries/{deliveryId}/_redeliver    queues the delivery again
+func initWebhookRoutes() {
+	wr := r.With(allowAnyOriginMiddleware, jwtAuthMiddleware(false), webhookRootMiddleware)
+	wr.Get(webhooksURI, webhookListHandler)
+	wr.Post(webhooksURI, webhookCreateHandler)
+	wr.Get(webhooksURI+"/{id}", webhookGetHandler)
+	wr.Delete(webhooksURI+"/{id}", webhookDeleteHandler)
+	wr.Get(webhooksURI+"/{id}/deliveries", webhookDeliveriesHandler)
+	wr.Post(webhooksURI+"/{id}/deliveries/{deliveryId}/_redeliver", webhookRedeliverHandler)
+
+	go webhookEnqueuer()
+	go webhookDispatcher()
+	go webhookLogCleaner()
+}
+
+func webhookRootMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cred, ok := r.Context().Value(credKey).(credentials)
+		if !ok {
+			log.Warn("[webhooks]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		if !isRootCredentials(cred) {
+			errorResponse(w, r, http.StatusForbidden, nil)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isRootCredentials(cred credentials) bool {
+	if fmt.Sprint(cred.userID) == hookRootUserID {
+		return true
+	}
+
+	if cred.claims == nil {
+		return false
+	}
+
+	roles, _ := cred.claims.Extra["roles"].([]interface{})
+	for _, role := range roles {
+		if fmt.Sprint(role) == hookRootUserID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// publicWebhookSubscription returns copy of the subscription with the masked secret.
+func publicWebhookSubscription(s *webhookSubscription) *webhookSubscription {
+	res := *s
+	if len(res.Secret) > 0 {
+		res.Secret = webhookSecretMask
+	}
+
+	return &res
+}
+
+func webhookListHandler(w http.ResponseWriter, r *http.Request) {
+	res := []*webhookSubscription{}
+	for _, s := range allWebhookSubscriptions() {
+		res = append(res, publicWebhookSubscription(s))
+	}
+
+	apiResponse(w, r, http.StatusOK, res)
+}
+
+func webhookCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var req webhookSubscriptionRequest
+	if err := decodeRequestBody(w, r, &req); err != nil {
+		errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var fields []fieldError
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		fields = append(fields, fieldError{Field: "url", Code: validationCodePattern, Message: "url must be absolute http or https url"})
+	}
+
+	if _, ok := getStoreConfig(req.Store); !ok {
+		fields = append(fields, fieldError{Field: "store", Code: validationCodeOptions, Message: fmt.Sprintf("store %q not found", req.Store)})
+	}
+
+	if len(fields) > 0 {
+		errorResponse(w, r, 0, newAPIError(http.StatusUnprocessableEntity, "validation_failed", "subscription is invalid", fields...))
+		return
+	}
+
+	// generated secret is returned only once in the create response
+	secret := req.Secret
+	if len(secret) == 0 {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		secret = hex.EncodeToString(b)
+	}
+
+	cred, _ := r.Context().Value(credKey).(credentials)
+	s := &webhookSubscription{
+		ID:        uuid.NewV4(),
+		URL:       req.URL,
+		Store:     req.Store,
+		Events:    req.Events,
+		Secret:    secret,
+		CreatedBy: cred.userID,
+		CreatedAt: time.Now(),
+	}
+
+	if err := saveWebhookSubscription(s); err != nil {
+		log.Errorf("[webhooks] can't save subscription, error: %v", err)
+		errorResponse(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.Header().Set("Location", webhooksURI+"/"+s.ID)
+	apiResponse(w, r, http.StatusCreated, s)
+}
+
+func webhookGetHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := getWebhookSubscription(chi.URLParam(r, "id"))
+	if !ok {
+		errorResponse(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	apiResponse(w, r, http.StatusOK, publicWebhookSubscription(s))
+}
+
+func webhookDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := getWebhookSubscription(chi.URLParam(r, "id"))
+	if !ok {
+		errorResponse(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	if s.Config {
+		errorResponse(w, r, 0, newAPIError(http.StatusConflict, "conflict", "subscription from the store settings can't be deleted"))
+		return
+	}
+
+	if err := deleteWebhookSubscription(s.ID); err != nil {
+		log.Errorf("[webhooks] can't delete subscription %s, error: %v", s.ID, err)
+		errorResponse(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func webhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := getWebhookSubscription(chi.URLParam(r, "id"))
+	if !ok {
+		errorResponse(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	take := webhookDefaultDeliveryTake
+	if t := r.URL.Query().Get("take"); len(t) > 0 {
+		n, err := strconv.Atoi(t)
+		if err != nil || n <= 0 {
+			errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("invalid take param"))
+			return
+		}
+
+		take = n
+	}
+
+	apiResponse(w, r, http.StatusOK, webhookDeliveries(s.ID, take))
+}
+
+func webhookRedeliverHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := getWebhookSubscription(chi.URLParam(r, "id"))
+	if !ok {
+		errorResponse(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	orig, ok := loadWebhookDelivery(chi.URLParam(r, "deliveryId"))
+	if !ok || orig.SubscriptionID != s.ID {
+		errorResponse(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	d, err := redeliverWebhook(s, orig)
+	if err != nil {
+		log.Errorf("[webhooks] can't queue redelivery of %s, error: %v", orig.ID, err)
+		errorResponse(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	apiResponse(w, r, http.StatusAccepted, d)
+}
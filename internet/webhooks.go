@@ -0,0 +1,606 @@
+package internet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getblank/uuid"
+	"github.com/json-iterator/go"
+)
+
+const (
+	webhookSubscriptionsBucket = "webhook-subscriptions"
+	webhookDeliveriesBucket    = "webhook-deliveries"
+	webhookQueueBucket         = "webhook-queue"
+
+	webhookStatusPending   = "pending"
+	webhookStatusDelivered = "delivered"
+	webhookStatusFailed    = "failed"
+
+	headerWebhookSignature = "X-Blank-Signature"
+	headerWebhookEvent     = "X-Blank-Event"
+	headerWebhookDelivery  = "X-Blank-Delivery"
+
+	webhookConfigIDPrefix   = "config:"
+	webhookDefaultEvent     = "change"
+	webhookDispatchPeriod   = time.Second
+	webhookRetryBaseDelay   = 10 * time.Second
+	webhookRetryMaxDelay    = time.Hour
+	webhookDedupWindow      = time.Minute
+	webhookEventsBufferSize = 1000
+	webhookLogTTL           = 7 * 24 * time.Hour
+	webhookLogCleanupPeriod = time.Hour
+)
+
+var (
+	webhookMaxAttempts = 8
+	webhookConcurrency = 4
+	webhookClient      = &http.Client{Timeout: 10 * time.Second}
+
+	// subscriptions created with API, they are kept in bolt and loaded on start
+	webhookSubscriptions       = map[string]*webhookSubscription{}
+	webhookSubscriptionsLocker sync.RWMutex
+
+	webhookEvents = make(chan webhookEvent, webhookEventsBufferSize)
+
+	webhookInFlight       = map[string]struct{}{}
+	webhookInFlightLocker sync.Mutex
+)
+
+// webhookSettings is the webhook subscription from the store settings, e.g.:
+//
+//	"webhooks": [{"url": "https://example.com/hook", "events": ["create", "update"], "secretEnv": "ORDERS_WEBHOOK_SECRET"}]
+type webhookSettings struct {
+	URL       string   `json:"url"`
+	Events    []string `json:"events,omitempty"`
+	Secret    string   `json:"secret,omitempty"`
+	SecretEnv string   `json:"secretEnv,omitempty"`
+}
+
+// webhookSubscription receives store events published by workers. Empty Events means all events of the store.
+type webhookSubscription struct {
+	ID        string      `json:"_id"`
+	URL       string      `json:"url"`
+	Store     string      `json:"store"`
+	Events    []string    `json:"events,omitempty"`
+	Secret    string      `json:"secret,omitempty"`
+	CreatedBy interface{} `json:"createdBy,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Config    bool        `json:"config,omitempty"`
+}
+
+func (s *webhookSubscription) matches(event string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, e := range s.Events {
+		if e == event || e == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// webhookDelivery is the queued event for the subscription with the log of delivery attempts.
+type webhookDelivery struct {
+	ID             string              `json:"_id"`
+	SubscriptionID string              `json:"subscriptionId"`
+	Store          string              `json:"store"`
+	Event          string              `json:"event"`
+	Payload        jsoniter.RawMessage `json:"payload"`
+	Status         string              `json:"status"`
+	Attempts       []webhookAttempt    `json:"attempts"`
+	NextAttemptAt  time.Time           `json:"nextAttemptAt,omitempty"`
+	RedeliveryOf   string              `json:"redeliveryOf,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt"`
+}
+
+type webhookEvent struct {
+	uri   string
+	event interface{}
+}
+
+type webhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+type webhookPayload struct {
+	ID        string      `json:"id"`
+	Store     string      `json:"store"`
+	Event     string      `json:"event"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// storeWebhookSubscriptions returns subscriptions of the store from the settings and API.
+func storeWebhookSubscriptions(storeName string) []*webhookSubscription {
+	var res []*webhookSubscription
+	for i, ws := range getStoreSettings(storeName).Webhooks {
+		secret := ws.Secret
+		if len(ws.SecretEnv) > 0 {
+			secret = os.Getenv(ws.SecretEnv)
+		}
+
+		res = append(res, &webhookSubscription{
+			ID:     webhookConfigIDPrefix + storeName + ":" + strconv.Itoa(i),
+			URL:    ws.URL,
+			Store:  storeName,
+			Events: ws.Events,
+			Secret: secret,
+			Config: true,
+		})
+	}
+
+	webhookSubscriptionsLocker.RLock()
+	for _, s := range webhookSubscriptions {
+		if s.Store == storeName {
+			res = append(res, s)
+		}
+	}
+	webhookSubscriptionsLocker.RUnlock()
+
+	return res
+}
+
+// allWebhookSubscriptions returns subscriptions of all stores ordered by store and creation time.
+func allWebhookSubscriptions() []*webhookSubscription {
+	settingsLocker.RLock()
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	settingsLocker.RUnlock()
+
+	webhookSubscriptionsLocker.RLock()
+	for _, s := range webhookSubscriptions {
+		names = append(names, s.Store)
+	}
+	webhookSubscriptionsLocker.RUnlock()
+
+	sort.Strings(names)
+	res := []*webhookSubscription{}
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+
+		subs := storeWebhookSubscriptions(name)
+		sort.SliceStable(subs, func(i, j int) bool { return subs[i].CreatedAt.Before(subs[j].CreatedAt) })
+		res = append(res, subs...)
+	}
+
+	return res
+}
+
+// getWebhookSubscription returns subscription by id, config subscriptions have ids config:<store>:<index>.
+func getWebhookSubscription(id string) (*webhookSubscription, bool) {
+	if strings.HasPrefix(id, webhookConfigIDPrefix) {
+		parts := strings.Split(strings.TrimPrefix(id, webhookConfigIDPrefix), ":")
+		if len(parts) != 2 {
+			return nil, false
+		}
+
+		for _, s := range storeWebhookSubscriptions(parts[0]) {
+			if s.ID == id {
+				return s, true
+			}
+		}
+
+		return nil, false
+	}
+
+	webhookSubscriptionsLocker.RLock()
+	defer webhookSubscriptionsLocker.RUnlock()
+
+	s, ok := webhookSubscriptions[id]
+	return s, ok
+}
+
+func saveWebhookSubscription(s *webhookSubscription) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err := localDB.Save(webhookSubscriptionsBucket, s.ID, data); err != nil {
+		return err
+	}
+
+	webhookSubscriptionsLocker.Lock()
+	webhookSubscriptions[s.ID] = s
+	webhookSubscriptionsLocker.Unlock()
+
+	return nil
+}
+
+func deleteWebhookSubscription(id string) error {
+	if err := localDB.Delete(webhookSubscriptionsBucket, id); err != nil {
+		return err
+	}
+
+	webhookSubscriptionsLocker.Lock()
+	delete(webhookSubscriptions, id)
+	webhookSubscriptionsLocker.Unlock()
+
+	return nil
+}
+
+func loadWebhookSubscriptions() {
+	all, err := localDB.GetAll(webhookSubscriptionsBucket)
+	if err != nil {
+		return
+	}
+
+	res := map[string]*webhookSubscription{}
+	for _, data := range all {
+		var s webhookSubscription
+		if err := json.Unmarshal(data, &s); err != nil {
+			log.Errorf("[webhooks] can't unmarshal subscription, error: %v", err)
+			continue
+		}
+
+		res[s.ID] = &s
+	}
+
+	webhookSubscriptionsLocker.Lock()
+	webhookSubscriptions = res
+	webhookSubscriptionsLocker.Unlock()
+}
+
+// onWebhookEvent passes the store event to webhookEnqueuer. It is called in the worker publish path,
+// so it never blocks: events are dropped with warning if the queue is full.
+func onWebhookEvent(uri string, event interface{}) {
+	if !strings.HasPrefix(uri, uriSubStores+".") {
+		return
+	}
+
+	select {
+	case webhookEvents <- webhookEvent{uri: uri, event: event}:
+	default:
+		log.Warnf("[webhooks] event queue is full, event for uri %s is dropped", uri)
+	}
+}
+
+// webhookEnqueuer saves deliveries of the store events for the matching subscriptions.
+// Workers send the same change to every group of subscribers, so events with the same eventId
+// are queued once per subscription. Events without eventId are never merged.
+func webhookEnqueuer() {
+	seen := map[string]time.Time{}
+	prune := time.NewTicker(webhookDedupWindow)
+	defer prune.Stop()
+
+	for {
+		select {
+		case e := <-webhookEvents:
+			queueWebhookEvent(e, seen)
+		case now := <-prune.C:
+			for k, at := range seen {
+				if now.Sub(at) > webhookDedupWindow {
+					delete(seen, k)
+				}
+			}
+		}
+	}
+}
+
+func queueWebhookEvent(e webhookEvent, seen map[string]time.Time) {
+	storeName := strings.TrimPrefix(e.uri, uriSubStores+".")
+	subs := storeWebhookSubscriptions(storeName)
+	if len(subs) == 0 {
+		return
+	}
+
+	eventName := webhookDefaultEvent
+	var eventID string
+	if m, ok := e.event.(map[string]interface{}); ok {
+		if name, ok := m["event"].(string); ok && len(name) > 0 {
+			eventName = name
+		}
+
+		eventID, _ = m["eventId"].(string)
+	}
+
+	for _, s := range subs {
+		if !s.matches(eventName) {
+			continue
+		}
+
+		if len(eventID) > 0 {
+			key := s.ID + ":" + eventID
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = time.Now()
+		}
+
+		if _, err := enqueueWebhook(s, eventName, e.event, ""); err != nil {
+			log.Errorf("[webhooks] can't queue delivery for subscription %s, error: %v", s.ID, err)
+		}
+	}
+}
+
+func enqueueWebhook(s *webhookSubscription, eventName string, data interface{}, redeliveryOf string) (*webhookDelivery, error) {
+	now := time.Now()
+	d := &webhookDelivery{
+		ID:             uuid.NewV4(),
+		SubscriptionID: s.ID,
+		Store:          s.Store,
+		Event:          eventName,
+		Status:         webhookStatusPending,
+		Attempts:       []webhookAttempt{},
+		NextAttemptAt:  now,
+		RedeliveryOf:   redeliveryOf,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	payload, err := json.Marshal(webhookPayload{ID: d.ID, Store: s.Store, Event: eventName, Data: data, CreatedAt: now})
+	if err != nil {
+		return nil, err
+	}
+
+	d.Payload = jsoniter.RawMessage(payload)
+	if err := saveWebhookDelivery(d); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// redeliverWebhook queues the copy of the delivery with the same payload.
+func redeliverWebhook(s *webhookSubscription, orig *webhookDelivery) (*webhookDelivery, error) {
+	now := time.Now()
+	d := &webhookDelivery{
+		ID:             uuid.NewV4(),
+		SubscriptionID: s.ID,
+		Store:          orig.Store,
+		Event:          orig.Event,
+		Payload:        orig.Payload,
+		Status:         webhookStatusPending,
+		Attempts:       []webhookAttempt{},
+		NextAttemptAt:  now,
+		RedeliveryOf:   orig.ID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	return d, saveWebhookDelivery(d)
+}
+
+// saveWebhookDelivery saves the delivery to the log and adds pending delivery to the queue.
+func saveWebhookDelivery(d *webhookDelivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	if err := localDB.Save(webhookDeliveriesBucket, d.ID, data); err != nil {
+		return err
+	}
+
+	if d.Status == webhookStatusPending {
+		return localDB.Save(webhookQueueBucket, d.ID, []byte(d.NextAttemptAt.Format(time.RFC3339Nano)))
+	}
+
+	return localDB.Delete(webhookQueueBucket, d.ID)
+}
+
+func loadWebhookDelivery(id string) (*webhookDelivery, bool) {
+	data, err := localDB.Get(webhookDeliveriesBucket, id)
+	if err != nil {
+		return nil, false
+	}
+
+	var d webhookDelivery
+	if err := json.Unmarshal(data, &d); err != nil {
+		log.Errorf("[webhooks] can't unmarshal delivery, error: %v", err)
+		return nil, false
+	}
+
+	return &d, true
+}
+
+// webhookDeliveries returns the delivery log of the subscription, newest first.
+func webhookDeliveries(subscriptionID string, take int) []*webhookDelivery {
+	all, err := localDB.GetAll(webhookDeliveriesBucket)
+	if err != nil {
+		return []*webhookDelivery{}
+	}
+
+	res := []*webhookDelivery{}
+	for _, data := range all {
+		var d webhookDelivery
+		if err := json.Unmarshal(data, &d); err != nil || d.SubscriptionID != subscriptionID {
+			continue
+		}
+
+		res = append(res, &d)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	if take > 0 && len(res) > take {
+		res = res[:take]
+	}
+
+	return res
+}
+
+// webhookSignature signs "<timestamp>.<payload>" with HMAC SHA-256, the header has the same format
+// as the stripe preset of the hook signature verification: t=<timestamp>,v1=<hex>.
+func webhookSignature(secret string, payload []byte, at time.Time) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(payload)
+
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay returns delay before the next attempt after the number of failed attempts.
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookRetryMaxDelay {
+			return webhookRetryMaxDelay
+		}
+	}
+
+	return delay
+}
+
+// sendWebhook makes one delivery attempt and updates the delivery status.
+func sendWebhook(d *webhookDelivery) {
+	s, ok := getWebhookSubscription(d.SubscriptionID)
+	now := time.Now()
+	attempt := webhookAttempt{At: now}
+	if !ok {
+		attempt.Error = "subscription not found"
+		d.Status = webhookStatusFailed
+	} else {
+		err := postWebhook(s, d, &attempt)
+		attempt.DurationMs = int64(time.Since(now) / time.Millisecond)
+		switch {
+		case err == nil:
+			d.Status = webhookStatusDelivered
+		case len(d.Attempts)+1 >= webhookMaxAttempts:
+			attempt.Error = err.Error()
+			d.Status = webhookStatusFailed
+		default:
+			attempt.Error = err.Error()
+			d.NextAttemptAt = now.Add(webhookRetryDelay(len(d.Attempts) + 1))
+		}
+	}
+
+	d.Attempts = append(d.Attempts, attempt)
+	d.UpdatedAt = time.Now()
+	if err := saveWebhookDelivery(d); err != nil {
+		log.Errorf("[webhooks] can't save delivery %s, error: %v", d.ID, err)
+	}
+}
+
+func postWebhook(s *webhookSubscription, d *webhookDelivery, attempt *webhookAttempt) error {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(headerContentType, applicationJSON)
+	req.Header.Set(headerWebhookEvent, d.Event)
+	req.Header.Set(headerWebhookDelivery, d.ID)
+	if len(s.Secret) > 0 {
+		req.Header.Set(headerWebhookSignature, webhookSignature(s.Secret, d.Payload, time.Now()))
+	}
+
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	attempt.StatusCode = res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", res.Status)
+	}
+
+	return nil
+}
+
+// webhookDispatcher sends due deliveries from the queue. Queue is kept in bolt, so deliveries survive restarts.
+func webhookDispatcher() {
+	loadWebhookSubscriptions()
+	sem := make(chan struct{}, webhookConcurrency)
+	for range time.Tick(webhookDispatchPeriod) {
+		ids, err := localDB.GetAllKeys(webhookQueueBucket)
+		if err != nil {
+			continue
+		}
+
+		now := time.Now()
+		for _, id := range ids {
+			data, err := localDB.Get(webhookQueueBucket, id)
+			if err != nil {
+				continue
+			}
+
+			if next, err := time.Parse(time.RFC3339Nano, string(data)); err == nil && next.After(now) {
+				continue
+			}
+
+			webhookInFlightLocker.Lock()
+			_, inFlight := webhookInFlight[id]
+			if !inFlight {
+				webhookInFlight[id] = struct{}{}
+			}
+			webhookInFlightLocker.Unlock()
+			if inFlight {
+				continue
+			}
+
+			sem <- struct{}{}
+			go func(id string) {
+				defer func() {
+					webhookInFlightLocker.Lock()
+					delete(webhookInFlight, id)
+					webhookInFlightLocker.Unlock()
+					<-sem
+				}()
+
+				d, ok := loadWebhookDelivery(id)
+				if !ok || d.Status != webhookStatusPending {
+					localDB.Delete(webhookQueueBucket, id)
+					return
+				}
+
+				sendWebhook(d)
+			}(id)
+		}
+	}
+}
+
+// webhookLogCleaner removes finished deliveries after webhookLogTTL.
+func webhookLogCleaner() {
+	for range time.Tick(webhookLogCleanupPeriod) {
+		ids, err := localDB.GetAllKeys(webhookDeliveriesBucket)
+		if err != nil {
+			continue
+		}
+
+		for _, id := range ids {
+			d, ok := loadWebhookDelivery(id)
+			if ok && d.Status != webhookStatusPending && time.Since(d.UpdatedAt) > webhookLogTTL {
+				localDB.Delete(webhookDeliveriesBucket, id)
+			}
+		}
+	}
+}
+
+func init() {
+	if s := os.Getenv("BLANK_WEBHOOK_MAX_ATTEMPTS"); len(s) > 0 {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			webhookMaxAttempts = n
+		}
+	}
+
+	if s := os.Getenv("BLANK_WEBHOOK_CONCURRENCY"); len(s) > 0 {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			webhookConcurrency = n
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package internet
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWebhookSubscriptionMatches(t *testing.T) {
+	testData := []struct {
+		events   []string
+		event    string
+		expected bool
+	}{
+		{nil, "create", true},
+		{[]string{"*"}, "delete", true},
+		{[]string{"create", "update"}, "update", true},
+		{[]string{"create", "update"}, "delete", false},
+	}
+
+	for _, v := range testData {
+		s := webhookSubscription{Events: v.events}
+		if s.matches(v.event) != v.expected {
+			t.Errorf("matches(%q) with events %v: expected %v", v.event, v.events, v.expected)
+		}
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	testData := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, time.Hour},
+	}
+
+	for _, v := range testData {
+		if d := webhookRetryDelay(v.attempts); d != v.expected {
+			t.Errorf("webhookRetryDelay(%d): expected %v, got %v", v.attempts, v.expected, d)
+		}
+	}
+}
+
+// Webhook signature must be verifiable with the stripe preset of the hook signature verification.
+func TestWebhookSignature(t *testing.T) {
+	payload := []byte(`{"id":"1","store":"orders","event":"create"}`)
+	now := time.Unix(1600000000, 0)
+	sig := hookSignature{Preset: signaturePresetStripe, Secret: "s"}
+
+	header := http.Header{"Stripe-Signature": {webhookSignature("s", payload, now)}}
+	if err := sig.verify(header, payload, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	header = http.Header{"Stripe-Signature": {webhookSignature("other", payload, now)}}
+	if err := sig.verify(header, payload, now); err != errSignatureInvalid {
+		t.Fatalf("expected errSignatureInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package internet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getblank/blank-router/taskq"
+	"github.com/getblank/blank-sr/config"
+)
+
+var (
+	widgetsConcurrency = 8
+	// defaultWidgetTimeout is used for widgets of the batch if timeout is not provided in the request
+	defaultWidgetTimeout = 30 * time.Second
+)
+
+// widgetLoadRequest is the body of the batch load request, e.g.:
+//
+//	{"timeout": "10s", "widgets": [{"widgetId": "sales"}, {"key": "sales2019", "widgetId": "sales", "data": {"year": 2019}}]}
+//
+// Key identifies the widget in the response. It is required if the same widget is loaded with different params.
+type widgetLoadRequest struct {
+	Timeout string          `json:"timeout,omitempty"`
+	Widgets []widgetRequest `json:"widgets"`
+}
+
+type widgetRequest struct {
+	Key      string      `json:"key,omitempty"`
+	WidgetID string      `json:"widgetId"`
+	ItemID   interface{} `json:"itemId,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+type widgetResult struct {
+	Key      string      `json:"key"`
+	WidgetID string      `json:"widgetId"`
+	Status   int         `json:"status"`
+	Data     interface{} `json:"data,omitempty"`
+	Error    *apiError   `json:"error,omitempty"`
+}
+
+type widgetsResponse struct {
+	Results map[string]interface{} `json:"results"`
+	Errors  map[string]*apiError   `json:"errors"`
+}
+
+// restWidgetsBatchLoadHandler loads data of the widgets in parallel. By default it responds with all results
+// when every widget is loaded or timed out. With Accept: application/x-ndjson or text/event-stream
+// each result is written as soon as it is ready.
+func restWidgetsBatchLoadHandler(store config.Store) http.HandlerFunc {
+	widgets := make(map[string]bool, len(store.Widgets))
+	for _, w := range store.Widgets {
+		widgets[w.ID] = true
+	}
+
+	idIsInt := store.Props["_id"].Type == config.PropInt
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(credKey)
+		if c == nil {
+			log.Warn("[rest widgets load]: no cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		cred, ok := c.(credentials)
+		if !ok {
+			log.Warn("[rest widgets load]: invalid cred in echo context")
+			errorResponse(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		var req widgetLoadRequest
+		if err := decodeRequestBody(w, r, &req); err != nil {
+			errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		timeout := defaultWidgetTimeout
+		if len(req.Timeout) > 0 {
+			d, err := time.ParseDuration(req.Timeout)
+			if err != nil || d <= 0 {
+				errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("invalid timeout %q", req.Timeout))
+				return
+			}
+
+			timeout = d
+		}
+
+		keys := make(map[string]bool, len(req.Widgets))
+		for i := range req.Widgets {
+			wr := &req.Widgets[i]
+			if !widgets[wr.WidgetID] {
+				errorResponse(w, r, 0, newAPIError(http.StatusNotFound, "not_found", fmt.Sprintf("widget %q not found", wr.WidgetID)))
+				return
+			}
+
+			if len(wr.Key) == 0 {
+				wr.Key = wr.WidgetID
+			}
+
+			if keys[wr.Key] {
+				errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("duplicate widget key %q", wr.Key))
+				return
+			}
+			keys[wr.Key] = true
+
+			if n, ok := numberValue(wr.ItemID); ok && idIsInt {
+				wr.ItemID = int(n)
+			}
+		}
+
+		var tokenInfo map[string]interface{}
+		if cred.claims != nil {
+			tokenInfo = cred.claims.toMap()
+		}
+
+		results := make(chan widgetResult)
+		go func() {
+			loadWidgets(r.Context(), store.Store, cred.userID, tokenInfo, req.Widgets, timeout, results)
+			close(results)
+		}()
+
+		accept := r.Header.Get("Accept")
+		switch {
+		case strings.Contains(accept, applicationNDJSON):
+			streamWidgetResults(w, applicationNDJSON, results, func(res widgetResult) ([]byte, error) {
+				encoded, err := json.Marshal(res)
+				return append(encoded, '\n'), err
+			})
+		case strings.Contains(accept, textEventStream):
+			streamWidgetResults(w, textEventStream, results, func(res widgetResult) ([]byte, error) {
+				encoded, err := json.Marshal(res)
+				return []byte(fmt.Sprintf("event: widget\ndata: %s\n\n", encoded)), err
+			})
+		default:
+			resp := widgetsResponse{Results: map[string]interface{}{}, Errors: map[string]*apiError{}}
+			for res := range results {
+				if res.Error != nil {
+					resp.Errors[res.Key] = res.Error
+					continue
+				}
+
+				resp.Results[res.Key] = res.Data
+			}
+
+			apiResponse(w, r, http.StatusOK, resp)
+		}
+	}
+}
+
+func streamWidgetResults(w http.ResponseWriter, contentType string, results <-chan widgetResult, encode func(widgetResult) ([]byte, error)) {
+	w.Header().Set(headerContentType, contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	for res := range results {
+		encoded, err := encode(res)
+		if err != nil {
+			log.Errorf("[rest widgets load] can't encode result of widget %s, error: %v", res.Key, err)
+			continue
+		}
+
+		if _, err := w.Write(encoded); err != nil {
+			log.Debugf("[rest widgets load] write error: %v", err)
+			continue
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// loadWidgets pushes WidgetData tasks with bounded concurrency and sends results as soon as they are ready.
+func loadWidgets(ctx context.Context, storeName string, userID interface{}, tokenInfo map[string]interface{}, widgets []widgetRequest, timeout time.Duration, results chan<- widgetResult) {
+	sem := make(chan struct{}, widgetsConcurrency)
+	var wg sync.WaitGroup
+	for i := range widgets {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(wr widgetRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			results <- loadWidget(ctx, storeName, userID, tokenInfo, wr, timeout)
+		}(widgets[i])
+	}
+
+	wg.Wait()
+}
+
+func loadWidget(ctx context.Context, storeName string, userID interface{}, tokenInfo map[string]interface{}, wr widgetRequest, timeout time.Duration) widgetResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t := taskq.Task{
+		Type:   taskq.WidgetData,
+		UserID: userID,
+		Store:  storeName,
+		Arguments: map[string]interface{}{
+			"widgetId": wr.WidgetID,
+			"itemId":   wr.ItemID,
+			"data":     wr.Data,
+		},
+	}
+	if tokenInfo != nil {
+		t.Arguments["tokenInfo"] = tokenInfo
+	}
+
+	res := widgetResult{Key: wr.Key, WidgetID: wr.WidgetID, Status: http.StatusOK}
+	data, err := pushTask(ctx, &t)
+	if err != nil {
+		res.Error = toAPIError(0, err)
+		res.Status = res.Error.Status
+		return res
+	}
+
+	res.Data = data
+	return res
+}
+
+func init() {
+	if c := os.Getenv("BLANK_WIDGETS_CONCURRENCY"); len(c) > 0 {
+		if n, err := strconv.Atoi(c); err == nil && n > 0 {
+			widgetsConcurrency = n
+		}
+	}
+
+	if s := os.Getenv("BLANK_WIDGET_TIMEOUT"); len(s) > 0 {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			defaultWidgetTimeout = d
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package internet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getblank/blank-sr/config"
+)
+
+func TestWidgetsBatchLoadValidation(t *testing.T) {
+	handler := restWidgetsBatchLoadHandler(config.Store{Store: "dashboard", Widgets: []config.Widget{{ID: "sales"}}})
+	testData := []struct {
+		body   string
+		status int
+	}{
+		{`{"widgets": [{"widgetId": "unknown"}]}`, http.StatusNotFound},
+		{`{"widgets": [{"widgetId": "sales"}, {"widgetId": "sales"}]}`, http.StatusBadRequest},
+		{`{"timeout": "soon", "widgets": [{"widgetId": "sales"}]}`, http.StatusBadRequest},
+	}
+
+	for i, v := range testData {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/dashboard/widgets/_load", strings.NewReader(v.body))
+		req = req.WithContext(context.WithValue(req.Context(), credKey, credentials{userID: "u1"}))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != v.status {
+			t.Errorf("#%d: status %d, expected: %d", i, rec.Code, v.status)
+		}
+	}
+}
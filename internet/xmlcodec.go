@@ -0,0 +1,301 @@
+package internet
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	xmlRootElement = "response"
+	xmlItemElement = "item"
+	xmlTypeAttr    = "type"
+	xmlKeyAttr     = "key"
+	xmlTypeObject  = "object"
+	xmlTypeArray   = "array"
+	xmlTypeInt     = "int"
+	xmlTypeFloat   = "float"
+	xmlTypeBool    = "bool"
+	xmlTypeDate    = "date"
+	xmlTypeNull    = "null"
+	xmlTypeString  = "string"
+)
+
+// xmlCodec encodes generic values as XML elements with type attributes, e.g.:
+//
+//	<response type="object"><name>John</name><age type="int">42</age><tags type="array"><item>a</item></tags></response>
+//
+// Strings have no type attribute. Keys which are not valid XML names are encoded as <item key="...">.
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string { return applicationXML }
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXMLValue(enc, xml.Name{Local: xmlRootElement}, nil, v); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if start, ok := tok.(xml.StartElement); ok {
+			res, err := decodeXMLValue(dec, start)
+			if err != nil {
+				return err
+			}
+
+			return assignDecoded(res, v)
+		}
+	}
+}
+
+func encodeXMLValue(enc *xml.Encoder, name xml.Name, attrs []xml.Attr, v interface{}) error {
+	start := xml.StartElement{Name: name, Attr: attrs}
+	var typ, text string
+	switch val := v.(type) {
+	case nil:
+		typ = xmlTypeNull
+	case map[string]interface{}:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlTypeAttr}, Value: xmlTypeObject})
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			elName, elAttrs := xml.Name{Local: k}, []xml.Attr(nil)
+			if !isXMLName(k) {
+				elName = xml.Name{Local: xmlItemElement}
+				elAttrs = []xml.Attr{{Name: xml.Name{Local: xmlKeyAttr}, Value: k}}
+			}
+
+			if err := encodeXMLValue(enc, elName, elAttrs, val[k]); err != nil {
+				return err
+			}
+		}
+
+		return enc.EncodeToken(start.End())
+	case []interface{}:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlTypeAttr}, Value: xmlTypeArray})
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+
+		for _, el := range val {
+			if err := encodeXMLValue(enc, xml.Name{Local: xmlItemElement}, nil, el); err != nil {
+				return err
+			}
+		}
+
+		return enc.EncodeToken(start.End())
+	case string:
+		text = val
+	case bool:
+		typ, text = xmlTypeBool, strconv.FormatBool(val)
+	case int64:
+		typ, text = xmlTypeInt, strconv.FormatInt(val, 10)
+	case int:
+		typ, text = xmlTypeInt, strconv.Itoa(val)
+	case float64:
+		typ, text = xmlTypeFloat, strconv.FormatFloat(val, 'g', -1, 64)
+	case time.Time:
+		typ, text = xmlTypeDate, val.Format(time.RFC3339Nano)
+	default:
+		// values of other types are encoded through their JSON representation
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+
+		var generic interface{}
+		if err := json.Unmarshal(encoded, &generic); err != nil {
+			return err
+		}
+
+		return encodeXMLValue(enc, name, attrs, generic)
+	}
+
+	if len(typ) > 0 {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: xmlTypeAttr}, Value: typ})
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if len(text) > 0 {
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// decodeXMLValue decodes element which start token was already read. Elements without type attribute
+// are objects if they have child elements and strings otherwise.
+func decodeXMLValue(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	typ := xmlAttr(start, xmlTypeAttr)
+	var text bytes.Buffer
+	var children []interface{}
+	var keys []string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLValue(dec, t)
+			if err != nil {
+				return nil, err
+			}
+
+			key := t.Name.Local
+			if k := xmlAttr(t, xmlKeyAttr); len(k) > 0 {
+				key = k
+			}
+
+			keys = append(keys, key)
+			children = append(children, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			return xmlElementValue(typ, string(bytes.TrimSpace(text.Bytes())), text.String(), keys, children)
+		}
+	}
+}
+
+func xmlElementValue(typ, trimmed, text string, keys []string, children []interface{}) (interface{}, error) {
+	switch typ {
+	case xmlTypeArray:
+		if children == nil {
+			return []interface{}{}, nil
+		}
+
+		return children, nil
+	case xmlTypeObject:
+		return xmlObject(keys, children), nil
+	case xmlTypeNull:
+		return nil, nil
+	case xmlTypeInt:
+		return strconv.ParseInt(trimmed, 10, 64)
+	case xmlTypeFloat:
+		return strconv.ParseFloat(trimmed, 64)
+	case xmlTypeBool:
+		return strconv.ParseBool(trimmed)
+	case xmlTypeDate:
+		return time.Parse(time.RFC3339Nano, trimmed)
+	case xmlTypeString:
+		return text, nil
+	case "":
+		if len(children) > 0 {
+			return xmlObject(keys, children), nil
+		}
+
+		return text, nil
+	}
+
+	return nil, fmt.Errorf("unknown xml value type %q", typ)
+}
+
+// xmlObject builds object from child elements, repeated elements become arrays.
+func xmlObject(keys []string, children []interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(keys))
+	repeated := map[string]bool{}
+	for i, k := range keys {
+		prev, ok := res[k]
+		switch {
+		case !ok:
+			res[k] = children[i]
+		case repeated[k]:
+			res[k] = append(prev.([]interface{}), children[i])
+		default:
+			res[k] = []interface{}{prev, children[i]}
+			repeated[k] = true
+		}
+	}
+
+	return res
+}
+
+func xmlAttr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+func isXMLName(s string) bool {
+	if len(s) == 0 || len(s) >= 3 && (s[0] == 'x' || s[0] == 'X') && (s[1] == 'm' || s[1] == 'M') && (s[2] == 'l' || s[2] == 'L') {
+		return false
+	}
+
+	for i, c := range s {
+		switch {
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		case i > 0 && (c == '-' || c == '.' || c >= '0' && c <= '9'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// assignDecoded sets decoded generic value to the pointer passed to Unmarshal.
+func assignDecoded(res interface{}, v interface{}) error {
+	switch p := v.(type) {
+	case *interface{}:
+		*p = res
+		return nil
+	case *map[string]interface{}:
+		m, ok := res.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("xml body must be an object")
+		}
+
+		*p = m
+		return nil
+	}
+
+	// typed destinations are filled through JSON representation
+	encoded, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, v)
+}
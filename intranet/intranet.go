@@ -7,7 +7,14 @@ import (
 	"github.com/getblank/blank-sr/config"
 )
 
-var onEventHandler = func(string, interface{}, []string) {}
+const errTaskCancelledText = "task cancelled"
+
+var (
+	onEventHandler = func(string, interface{}, []string) {}
+
+	cancelledTasks       = map[uint64]struct{}{}
+	cancelledTasksLocker sync.RWMutex
+)
 
 func Init() {
 	runServer()
@@ -18,6 +25,36 @@ func OnEvent(fn func(string, interface{}, []string)) {
 	onEventHandler = fn
 }
 
+// CancelTask marks the task as cancelled. Task which is not taken by a worker yet will be skipped,
+// the worker processing the task receives cancel event and can abort it.
+func CancelTask(taskID uint64) {
+	cancelledTasksLocker.Lock()
+	cancelledTasks[taskID] = struct{}{}
+	cancelledTasksLocker.Unlock()
+
+	select {
+	case taskCancelChan <- taskID:
+	default:
+		log.Warnf("Task cancel channel is full, worker will not be notified about cancelled task %d", taskID)
+	}
+}
+
+func isTaskCancelled(taskID uint64) bool {
+	cancelledTasksLocker.RLock()
+	defer cancelledTasksLocker.RUnlock()
+
+	_, ok := cancelledTasks[taskID]
+	return ok
+}
+
+// ForgetCancelledTask removes the cancel mark of the finished task. Task can be finished before it is marked,
+// so the mark must be removed when the result of the cancelled task is received.
+func ForgetCancelledTask(taskID uint64) {
+	cancelledTasksLocker.Lock()
+	delete(cancelledTasks, taskID)
+	cancelledTasksLocker.Unlock()
+}
+
 func srEventHandler(uri string, subscribers []string, event interface{}) {
 	if len(subscribers) == 0 {
 		return
@@ -1,7 +1,9 @@
 package intranet
 
 import (
+	"encoding/json"
 	"errors"
+	"expvar"
 	"net/http"
 	"os"
 	"strings"
@@ -29,6 +31,10 @@ const (
 	cronRunURI   = "cron.run"
 	uriSubStores = "com.stores"
 
+	// cancelTaskURI is the RPC workers call to check if the task was cancelled,
+	// also workers receive cancelled task ids as events on this uri.
+	cancelTaskURI = "task.cancelled"
+
 	rpcSessionNew        = "session.new"
 	rpcSessionCheck      = "session.check"
 	rpcSessionDelete     = "session.delete"
@@ -39,9 +45,13 @@ var (
 	wampServer           = wango.New()
 	log                  = logging.Logger()
 	taskWatchChan        = make(chan taskKeeper, 1000)
+	taskCancelChan       = make(chan uint64, 1000)
 	workerConnectChan    = make(chan string)
 	workerDisconnectChan = make(chan string)
 	listeningPort        = "2345"
+
+	// debugVars are the expvar vars served on /debug/vars, default vars like cmdline and memstats are not exposed
+	debugVars = []string{"blankCache"}
 )
 
 type taskKeeper struct {
@@ -53,6 +63,13 @@ type taskKeeper struct {
 func taskGetHandler(c *wango.Conn, uri string, args ...interface{}) (interface{}, error) {
 	log.Debugf("Get task request from client \"%s\"", c.ID())
 	t := taskq.Shift()
+	for isTaskCancelled(t.ID) {
+		log.Debugf("Shifted task id: \"%d\" was cancelled, skipping", t.ID)
+		taskq.Done(taskq.Result{ID: t.ID, Err: errTaskCancelledText})
+		ForgetCancelledTask(t.ID)
+		t = taskq.Shift()
+	}
+
 	log.Debugf("Shifted task id: \"%d\" type: \"%s\" for client \"%s\"", t.ID, t.Type, c.ID())
 	if c.Connected() {
 		taskWatchChan <- taskKeeper{c.ID(), t.ID, false}
@@ -102,8 +119,20 @@ func taskErrorHandler(c *wango.Conn, uri string, args ...interface{}) (interface
 		return nil, berrors.ErrInvalidArguments
 	}
 
-	err, ok := args[1].(string)
-	if !ok {
+	// structured errors are passed through the task queue as JSON objects, which are decoded for HTTP and WAMP clients
+	var err string
+	switch e := args[1].(type) {
+	case string:
+		err = e
+	case map[string]interface{}:
+		encoded, encodeErr := json.Marshal(e)
+		if encodeErr != nil {
+			log.Warnf("Invalid structured error in task.error RPC: %v", encodeErr)
+			return nil, berrors.ErrInvalidArguments
+		}
+
+		err = string(encoded)
+	default:
 		log.Warn("Invalid description in task.error RPC")
 		return nil, berrors.ErrInvalidArguments
 	}
@@ -120,6 +149,23 @@ func taskErrorHandler(c *wango.Conn, uri string, args ...interface{}) (interface
 	return nil, nil
 }
 
+// taskCancelledHandler returns true if the task with provided id was cancelled.
+// Workers can call it during long-running tasks to abort them.
+func taskCancelledHandler(c *wango.Conn, uri string, args ...interface{}) (interface{}, error) {
+	if len(args) < 1 {
+		log.Warn("Invalid task.cancelled RPC")
+		return nil, berrors.ErrInvalidArguments
+	}
+
+	id, ok := args[0].(float64)
+	if !ok {
+		log.Warnf("Invalid task.id %v in task.cancelled RPC", args[0])
+		return nil, berrors.ErrInvalidArguments
+	}
+
+	return isTaskCancelled(uint64(id)), nil
+}
+
 func cronRunHandler(c *wango.Conn, uri string, args ...interface{}) (interface{}, error) {
 	if len(args) < 2 {
 		log.Warn("Invalid cron.run RPC")
@@ -164,6 +210,7 @@ func internalOpenCallback(c *wango.Conn) {
 
 func internalCloseCallback(c *wango.Conn) {
 	log.Infof("Disconnected client from TQ: '%s'", c.ID())
+	abortWorkerStreams(c.ID())
 	workerDisconnectChan <- c.ID()
 }
 
@@ -243,9 +290,18 @@ func taskWatcher() {
 		case t := <-taskWatchChan:
 			if t.done {
 				delete(workerTasks[t.workerID], t.taskID)
+				ForgetCancelledTask(t.taskID)
 			} else {
 				workerTasks[t.workerID][t.taskID] = struct{}{}
 			}
+		case taskID := <-taskCancelChan:
+			for workerID, tasks := range workerTasks {
+				if _, ok := tasks[taskID]; ok {
+					log.Debugf("Notifying worker %s about cancelled task %d", workerID, taskID)
+					wampServer.SendEvent(cancelTaskURI, taskID, []string{workerID})
+					break
+				}
+			}
 		case workerID := <-workerConnectChan:
 			workerTasks[workerID] = map[uint64]struct{}{}
 		case workerID := <-workerDisconnectChan:
@@ -260,6 +316,7 @@ func taskWatcher() {
 					Err: errWorkerDisconnectedText,
 				}
 				taskq.Done(result)
+				ForgetCancelledTask(taskID)
 			}
 			delete(workerTasks, workerID)
 			log.Infof("All workers %s tasks closed.", workerID)
@@ -293,6 +350,7 @@ func checkErrorAndPanic(err error) {
 
 func runServer() {
 	go taskWatcher()
+	go streamsCleaner()
 
 	wampServer.SetSessionOpenCallback(internalOpenCallback)
 	wampServer.SetSessionCloseCallback(internalCloseCallback)
@@ -307,10 +365,14 @@ func runServer() {
 	checkErrorAndPanic(wampServer.RegisterRPCHandler(errorTaskURI, taskErrorHandler))
 	checkErrorAndPanic(wampServer.RegisterRPCHandler(publishURI, publishHandler))
 	checkErrorAndPanic(wampServer.RegisterRPCHandler(cronRunURI, cronRunHandler))
+	checkErrorAndPanic(wampServer.RegisterRPCHandler(cancelTaskURI, taskCancelledHandler))
+	checkErrorAndPanic(wampServer.RegisterRPCHandler(streamWriteURI, streamWriteHandler))
+	checkErrorAndPanic(wampServer.RegisterRPCHandler(streamEndURI, streamEndHandler))
 
 	checkErrorAndPanic(wampServer.RegisterRPCHandler(rpcSessionNew, sessionNewHandler))
 
 	checkErrorAndPanic(wampServer.RegisterSubHandler(uriSubStores, subStoresHandler, nil, nil))
+	checkErrorAndPanic(wampServer.RegisterSubHandler(cancelTaskURI, nil, nil, nil))
 
 	sr.Init(wampServer, srEventHandler)
 
@@ -343,6 +405,7 @@ func runServer() {
 	}
 
 	r.Get("/lib/", libHandler)
+	r.Get("/debug/vars", debugVarsHandler)
 
 	log.Info("TaskQueue will listen for connection on port ", listeningPort)
 	if _, err := registry.Register("taskQueue", "ws://127.0.0.1", listeningPort, "0", ""); err != nil {
@@ -355,6 +418,20 @@ func runServer() {
 	}
 }
 
+func debugVarsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := map[string]json.RawMessage{}
+	for _, name := range debugVars {
+		if v := expvar.Get(name); v != nil {
+			vars[name] = json.RawMessage(v.String())
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(vars); err != nil {
+		log.Debugf("[debugVarsHandler] write error: %v", err)
+	}
+}
+
 func libHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err := w.Write(appconfig.GetLibZip()); err != nil {
 		log.Debugf("[libHandler] write error: %v", err)
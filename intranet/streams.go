@@ -0,0 +1,271 @@
+package intranet
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/getblank/wango"
+
+	"github.com/getblank/blank-router/berrors"
+)
+
+const (
+	streamWriteURI = "stream.write"
+	streamEndURI   = "stream.end"
+
+	streamBufferSize = 16
+)
+
+var (
+	// streamTimeout is the max time the worker waits for the reader of the chunk
+	streamTimeout = time.Minute
+
+	streams       = map[string]*Stream{}
+	closedStreams = map[string]time.Time{} // closed by the reader, writes to them fail at once
+	streamsLocker sync.Mutex
+
+	// ErrStreamClosed is returned to the worker when the client is gone or the stream is aborted
+	ErrStreamClosed = errors.New("stream closed")
+	// ErrStreamTimeout is returned to the worker when the reader doesn't take the chunk in time
+	ErrStreamTimeout = errors.New("stream timeout")
+)
+
+// StreamChunk is the part of the response written by the worker. Event and ID are used in SSE streams.
+type StreamChunk struct {
+	Data  []byte
+	Event string
+	ID    string
+}
+
+// Stream is the response of the hook or action which the worker writes in chunks with stream.write RPC
+// and finishes with stream.end RPC. Every write waits until the chunk is taken by the reader,
+// so the worker can't get ahead of the client more than by the stream buffer.
+type Stream struct {
+	id        string
+	workerID  string
+	opened    bool
+	createdAt time.Time
+	chunks    chan StreamChunk
+	done      chan struct{}
+	once      sync.Once
+	err       error
+}
+
+// OpenStream returns the stream by id for reading. Worker can start writing before the stream is opened,
+// so the stream is created by the first of the reader or the writer. Reader must close the stream.
+func OpenStream(id string) *Stream {
+	s, _ := getStream(id, "", true)
+	return s
+}
+
+// getStream returns the stream by id, false means the stream is already closed by the reader.
+func getStream(id, workerID string, open bool) (*Stream, bool) {
+	streamsLocker.Lock()
+	defer streamsLocker.Unlock()
+
+	if _, ok := closedStreams[id]; ok && !open {
+		return nil, false
+	}
+
+	s, ok := streams[id]
+	if !ok {
+		s = &Stream{id: id, createdAt: time.Now(), chunks: make(chan StreamChunk, streamBufferSize), done: make(chan struct{})}
+		streams[id] = s
+	}
+
+	if len(workerID) > 0 {
+		s.workerID = workerID
+	}
+
+	if open {
+		s.opened = true
+	}
+
+	return s, true
+}
+
+// Next returns the next chunk of the stream. When the stream is ended it returns nil chunk
+// and the error passed by the worker to stream.end, nil if the stream is ended successfully.
+// If ctx is done before the next chunk, ctx error is returned and the stream can be read again.
+func (s *Stream) Next(ctx context.Context) (*StreamChunk, error) {
+	select {
+	case c := <-s.chunks:
+		return &c, nil
+	case <-s.done:
+		// chunks written before the end are returned first
+		select {
+		case c := <-s.chunks:
+			return &c, nil
+		default:
+		}
+
+		return nil, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// StreamTimeout returns the max time the worker waits for the reader of the chunk.
+// Readers use the same timeout waiting for the next chunk.
+func StreamTimeout() time.Duration {
+	return streamTimeout
+}
+
+// Close closes the stream, next writes of the worker return ErrStreamClosed.
+func (s *Stream) Close() {
+	s.finish(ErrStreamClosed)
+	streamsLocker.Lock()
+	delete(streams, s.id)
+	closedStreams[s.id] = time.Now()
+	streamsLocker.Unlock()
+}
+
+func (s *Stream) finish(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+func (s *Stream) write(c StreamChunk) error {
+	timer := time.NewTimer(streamTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-s.done:
+		return ErrStreamClosed
+	default:
+	}
+
+	select {
+	case s.chunks <- c:
+		return nil
+	case <-s.done:
+		return ErrStreamClosed
+	case <-timer.C:
+		s.finish(ErrStreamClosed)
+		return ErrStreamTimeout
+	}
+}
+
+// args: streamId string, data string, options {"encoding": "base64", "event": "name", "id": "1"}
+func streamWriteHandler(c *wango.Conn, uri string, args ...interface{}) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, berrors.ErrInvalidArguments
+	}
+
+	id, ok := args[0].(string)
+	if !ok || len(id) == 0 {
+		return nil, berrors.ErrInvalidArguments
+	}
+
+	data, ok := args[1].(string)
+	if !ok {
+		return nil, berrors.ErrInvalidArguments
+	}
+
+	chunk := StreamChunk{Data: []byte(data)}
+	if len(args) > 2 {
+		if opts, ok := args[2].(map[string]interface{}); ok {
+			if opts["encoding"] == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(data)
+				if err != nil {
+					return nil, berrors.ErrInvalidArguments
+				}
+
+				chunk.Data = decoded
+			}
+
+			chunk.Event, _ = opts["event"].(string)
+			chunk.ID, _ = opts["id"].(string)
+		}
+	}
+
+	s, ok := getStream(id, c.ID(), false)
+	if !ok {
+		return nil, ErrStreamClosed
+	}
+
+	if err := s.write(chunk); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// args: streamId string, error string
+func streamEndHandler(c *wango.Conn, uri string, args ...interface{}) (interface{}, error) {
+	if len(args) < 1 {
+		return nil, berrors.ErrInvalidArguments
+	}
+
+	id, ok := args[0].(string)
+	if !ok || len(id) == 0 {
+		return nil, berrors.ErrInvalidArguments
+	}
+
+	var err error
+	if len(args) > 1 {
+		if desc, ok := args[1].(string); ok && len(desc) > 0 {
+			err = errors.New(desc)
+		}
+	}
+
+	if s, ok := getStream(id, c.ID(), false); ok {
+		s.finish(err)
+	}
+
+	return nil, nil
+}
+
+// abortWorkerStreams finishes streams of the disconnected worker with error.
+func abortWorkerStreams(workerID string) {
+	streamsLocker.Lock()
+	var aborted []*Stream
+	for _, s := range streams {
+		if s.workerID == workerID {
+			aborted = append(aborted, s)
+		}
+	}
+	streamsLocker.Unlock()
+
+	for _, s := range aborted {
+		s.finish(errors.New("connection with worker lost"))
+	}
+}
+
+// streamsCleaner removes streams which are not opened by the reader in time, e.g. if the request is already gone.
+func streamsCleaner() {
+	for range time.Tick(streamTimeout) {
+		var expired []*Stream
+		streamsLocker.Lock()
+		for _, s := range streams {
+			if !s.opened && time.Since(s.createdAt) > streamTimeout {
+				expired = append(expired, s)
+			}
+		}
+
+		for id, closedAt := range closedStreams {
+			if time.Since(closedAt) > streamTimeout {
+				delete(closedStreams, id)
+			}
+		}
+		streamsLocker.Unlock()
+
+		for _, s := range expired {
+			s.Close()
+		}
+	}
+}
+
+func init() {
+	if s := os.Getenv("BLANK_STREAM_TIMEOUT"); len(s) > 0 {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			streamTimeout = d
+		}
+	}
+}
@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"crypto/rsa"
+	"fmt"
 
 	"github.com/getblank/blank-sr/sessionstore"
 
@@ -30,6 +31,19 @@ func CheckSession(apiKey string) (string, error) {
 	return userID, nil
 }
 
+// AccessToken returns JWT of the session with provided apiKey.
+func AccessToken(apiKey string) (string, error) {
+	s, err := sessionstore.GetByAPIKey(apiKey)
+	if err != nil {
+		return "", err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.AccessToken, nil
+}
+
 // DeleteSession delete session with provided apiKey from serviceRegistry
 func DeleteSession(apiKey string) error {
 	s, err := sessionstore.GetByAPIKey(apiKey)
@@ -83,6 +97,45 @@ func DeleteSubscription(apiKey, connID, uri string) error {
 	return nil
 }
 
+// Subscribers returns ids of the session connections subscribed to the uri.
+func Subscribers(apiKey, uri string) ([]string, error) {
+	s, err := sessionstore.GetByAPIKey(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	var res []string
+	for _, c := range s.Connections {
+		if _, ok := c.Subscriptions[uri]; ok {
+			res = append(res, c.ConnID)
+		}
+	}
+
+	return res, nil
+}
+
+// UserSubscribers returns ids of the connections of all user sessions subscribed to the uri.
+func UserSubscribers(userID interface{}, uri string) []string {
+	id := fmt.Sprint(userID)
+	var res []string
+	for _, s := range sessionstore.GetAll() {
+		s.RLock()
+		if fmt.Sprint(s.UserID) == id {
+			for _, c := range s.Connections {
+				if _, ok := c.Subscriptions[uri]; ok {
+					res = append(res, c.ConnID)
+				}
+			}
+		}
+		s.RUnlock()
+	}
+
+	return res
+}
+
 // PublicKeyBytes returns RSA public key as []byte
 func PublicKeyBytes() []byte {
 	return sessionstore.PublicKeyBytes()